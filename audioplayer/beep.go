@@ -0,0 +1,163 @@
+package audioplayer
+
+import (
+	"log/slog"
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+)
+
+// interruptedPlay is a music play put aside while beep is playing
+type interruptedPlay struct {
+	track   Track
+	content resolver.Content
+	play    int
+	minimum int
+}
+
+// destroyedTrack is a music track destroyed right before next content uri is opened
+type destroyedTrack struct {
+	trackID string
+	reason  playerevents.EndReason
+	ticks   int
+}
+
+// BeepTimeout is the amount of ticks beep may last; beep is over after that even if its end was not logged
+var BeepTimeout = 5
+
+// DestroyGrace is the amount of ticks destroyed play waits for next content uri before it is finalized
+var DestroyGrace = 2
+
+// flushDestroyed finalizes play of destroyed track, next content uri is not a beep
+func (p *AudioPlayer) flushDestroyed() {
+	if p.destroyed == nil {
+		return
+	}
+
+	d := p.destroyed
+	p.destroyed = nil
+	p.destroy(d.trackID, d.reason)
+}
+
+// Beep marks start of a beep
+//
+// Beeps are inserted in play queue as regular tracks: player destroys music track, opens beep uri and creates
+// a track for it. Current music play is put aside and restored when the same content uri is opened again after beep.
+func (p *AudioPlayer) Beep(uri string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	slog.Debug("beep", "uri", uri)
+
+	if d := p.destroyed; d != nil {
+		if p.CurrentTrack.PlayingFor == 0 {
+			// nothing to put aside, e.g. music track has ended before beep
+			p.flushDestroyed()
+		} else {
+			// music track is gone, along with tracks prepared for it
+			p.destroyed = nil
+			p.Prepared.Drop(d.trackID)
+			p.CurrentTrack.TrackID = ""
+		}
+	}
+
+	p.beeping = true
+	p.beepTicks = 0
+	if p.interrupted == nil && p.CurrentTrack.ContentURI != "" {
+		p.note("interrupted by beep %s", uri)
+		p.interrupted = &interruptedPlay{
+			track:   *p.CurrentTrack,
+			content: *p.CurrentContent,
+			play:    p.play,
+			minimum: p.minimumListenDuration,
+		}
+	}
+}
+
+// beepTimedOut ends beep which was followed neither by destruction of beep track nor by next content uri,
+// interrupted music play is counted again
+func (p *AudioPlayer) beepTimedOut() {
+	slog.Warn("beep has not ended in time", "ticks", p.beepTicks)
+	p.beeping = false
+	p.interrupted = nil
+	p.note("beep timed out")
+}
+
+// EndOfStream stops current track unless stream belongs to a beep
+func (p *AudioPlayer) EndOfStream() {
+	p.lock.Lock()
+	beeping := p.beeping
+	p.lock.Unlock()
+
+	if beeping {
+		return
+	}
+
+	p.lock.Lock()
+	p.flushDestroyed()
+	p.finish(playerevents.EndReasonEndOfStream)
+	p.lock.Unlock()
+
+	p.Stop()
+}
+
+// beepDestroyed handles destruction of beep track and music track destroyed because of beep
+//
+// Returns true if destroyed track has nothing to do with current music play
+func (p *AudioPlayer) beepDestroyed(trackID string) bool {
+	if _, ok := p.beepTracks[trackID]; ok {
+		delete(p.beepTracks, trackID)
+		p.beeping = false
+
+		// music track survived the beep
+		if p.interrupted != nil && p.interrupted.track.TrackID == p.CurrentTrack.TrackID && p.CurrentTrack.TrackID != "" {
+			p.interrupted = nil
+		}
+
+		return true
+	}
+
+	if p.interrupted != nil && p.interrupted.track.TrackID == trackID {
+		p.CurrentTrack.TrackID = ""
+		return true
+	}
+
+	return false
+}
+
+// restoreInterrupted restores music play interrupted by beep if uri belongs to it
+//
+// Otherwise interrupted play is finalized, same way DestroyTrack does
+func (p *AudioPlayer) restoreInterrupted(uri string) bool {
+	i := p.interrupted
+	p.interrupted = nil
+	p.beeping = false
+
+	if i.track.ContentURI == uri {
+		*p.CurrentTrack = i.track
+		p.CurrentTrack.TrackID = ""
+		p.CurrentContent = &i.content
+		p.play = i.play
+		p.requested = i.play
+		p.minimumListenDuration = i.minimum
+		slog.Debug("restored play after beep", "uri", uri, "for", p.CurrentTrack.PlayingFor)
+		p.note("resumed after beep")
+		return true
+	}
+
+	switch {
+	case i.content.Rating:
+		p.noteOn(i.play, "not resumed after beep, %s opened", uri)
+	case i.track.Excluded != "":
+		p.decide(i.play, VerdictNotSent, "not resumed after beep, %s opened, excluded by rule %s", uri, i.track.Excluded)
+	case i.content.Valid() && i.track.PlayingFor > 2:
+		p.emit(played(i.content, i.track, playerevents.EndReasonDestroyed), i.track.Uptime)
+		slog.Info("sent to scrobbler as skipped", "uri", i.track.ContentURI)
+		p.decide(i.play, VerdictSkipped, "not resumed after beep, %s opened, sent as skipped", uri)
+	default:
+		p.decide(i.play, VerdictNotSent, "not resumed after beep, %s opened", uri)
+	}
+
+	p.remember(i.content, i.track, playerevents.EndReasonDestroyed)
+
+	return false
+}
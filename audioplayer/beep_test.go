@@ -0,0 +1,194 @@
+package audioplayer
+
+import (
+	"os"
+	"scrobbler/parser"
+	"scrobbler/playerevents"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replayCapture feeds capture to player line by line, ticking once per second of log time
+func replayCapture(t *testing.T, p *AudioPlayer, filename string) {
+	t.Helper()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("cannot read capture: %s", err.Error())
+	}
+
+	pp := parser.LogParser{}
+	var last time.Time
+	for _, line := range strings.Split(string(data), "\n") {
+		if ts, ok := parser.Timestamp(line, 2018, time.UTC); ok {
+			if last.IsZero() {
+				last = ts
+			}
+
+			for !last.Add(time.Second).After(ts) {
+				last = last.Add(time.Second)
+				tick(t, p, 1)
+			}
+		}
+
+		event, err := pp.Event(line)
+		if err != nil {
+			t.Fatalf("Event() error = %v", err)
+		}
+
+		if event == nil {
+			continue
+		}
+
+		if err = p.Handle(event); err != nil {
+			t.Fatalf("Handle() error = %v", err)
+		}
+
+		if err = p.ApplyResolved(); err != nil {
+			t.Fatalf("ApplyResolved() error = %v", err)
+		}
+	}
+}
+
+func TestAudioPlayer_BeepCapture(t *testing.T) {
+	p := New().WithResolver(&DumbResolver{}).WithClock(&staticClock{}).WithPlayerEventEmitter(make(chan playerevents.PlayerEvent, 10)).
+		WithSyncResolve()
+	emitter := p.PlayerEventEmitter()
+
+	replayCapture(t, p, "test/beep.log")
+
+	if len(emitter) != 1 {
+		t.Fatalf("events = %d, want 1, play must survive beep", len(emitter))
+	}
+
+	e := (<-emitter).(playerevents.PlayerEventTrackListened)
+	if !e.Content.Rating || e.Content.Track != "/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac" {
+		t.Errorf("event = %v %v, want listen of track interrupted by beep", e.Content.Track, e.Content.Rating)
+	}
+}
+
+func TestAudioPlayer_BeepTimeout(t *testing.T) {
+	p := newTestPlayer(&DumbResolver{})
+	emitter := p.PlayerEventEmitter()
+
+	playFor(t, p, "/data/mnt/internal/MUSIC/1.flac", "TK_1", 2)
+
+	// beep end is not logged
+	p.Beep("/system/usr/share/sounds/WM_BEEP_VOLUME.wav")
+	tick(t, p, BeepTimeout+4)
+
+	if p.beeping {
+		t.Errorf("beeping = true, want beep over after %d ticks", BeepTimeout)
+	}
+
+	if len(emitter) != 1 {
+		t.Errorf("events = %d, want 1, music play must be counted after beep", len(emitter))
+	}
+}
+
+func TestAudioPlayer_Beep(t *testing.T) {
+	music := "/data/mnt/internal/MUSIC/1.flac"
+	tests := []struct {
+		name           string
+		beepFirst      bool // beep uri is opened before music track is destroyed
+		after          string
+		wantURI        string
+		wantPlayingFor int
+		wantEvents     int
+	}{
+		{
+			name:           "music resumed after beep, play kept",
+			after:          music,
+			wantURI:        music,
+			wantPlayingFor: 4,
+			wantEvents:     0,
+		},
+		{
+			name:           "other track after beep, interrupted play skipped",
+			after:          "/data/mnt/internal/MUSIC/2.flac",
+			wantURI:        "/data/mnt/internal/MUSIC/2.flac",
+			wantPlayingFor: 1,
+			wantEvents:     1,
+		},
+		{
+			name:           "beep opened before music track is destroyed, play kept",
+			beepFirst:      true,
+			after:          music,
+			wantURI:        music,
+			wantPlayingFor: 4,
+			wantEvents:     0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlayer(&DumbResolver{})
+			emitter := p.PlayerEventEmitter()
+
+			playFor(t, p, music, "TK_MUSIC_PID_312_PKT_131072_QUE_5_3", 3)
+
+			// track change order from test/beep.log: track destroyed, content uri opened, track created
+			if tt.beepFirst {
+				p.Beep("/system/usr/share/sounds/WM_BEEP_VOLUME.wav")
+				p.DestroyTrack("TK_MUSIC_PID_312_PKT_131072_QUE_5_3")
+			} else {
+				p.DestroyTrack("TK_MUSIC_PID_312_PKT_131072_QUE_5_3")
+				p.Beep("/system/usr/share/sounds/WM_BEEP_VOLUME.wav")
+			}
+			p.CreateTrack("TK_BEEP")
+			p.EndOfStream()
+			tick(t, p, 1)
+			p.DestroyTrack("TK_BEEP")
+
+			p.SetContentURI(tt.after)
+			p.CreateTrack("TK_MUSIC_PID_312_PKT_131072_QUE_5_4")
+			if tt.after != music {
+				if err := p.ApplyResolution(<-p.resolved); err != nil {
+					t.Fatalf("ApplyResolution() error = %v", err)
+				}
+			}
+			tick(t, p, 1)
+
+			if p.CurrentTrack.ContentURI != tt.wantURI {
+				t.Errorf("ContentURI = %v, want %v", p.CurrentTrack.ContentURI, tt.wantURI)
+			}
+
+			if p.CurrentTrack.PlayingFor != tt.wantPlayingFor {
+				t.Errorf("PlayingFor = %v, want %v", p.CurrentTrack.PlayingFor, tt.wantPlayingFor)
+			}
+
+			if len(emitter) != tt.wantEvents {
+				t.Errorf("events = %v, want %v", len(emitter), tt.wantEvents)
+			}
+
+			if p.modes.mode != (playerevents.PlayMode{}) {
+				t.Errorf("mode = %+v, want none", p.modes.mode)
+			}
+		})
+	}
+}
+
+func TestAudioPlayer_DestroyGrace(t *testing.T) {
+	p := newTestPlayer(&DumbResolver{})
+	emitter := p.PlayerEventEmitter()
+
+	playFor(t, p, "/data/mnt/internal/MUSIC/1.flac", "TK_1", 3)
+
+	// playback stopped, no uri follows
+	p.DestroyTrack("TK_1")
+	for i := 0; i <= DestroyGrace; i++ {
+		if len(emitter) != 0 {
+			t.Fatalf("events after %d ticks = %v, want 0", i, len(emitter))
+		}
+
+		tick(t, p, 1)
+	}
+
+	if len(emitter) != 1 {
+		t.Fatalf("events = %v, want 1", len(emitter))
+	}
+
+	if e := (<-emitter).(playerevents.PlayerEventTrackListened); e.ListenedFor != 3 || e.Reason != playerevents.EndReasonDestroyed {
+		t.Errorf("event = %v s %v, want 3 s %v", e.ListenedFor, e.Reason, playerevents.EndReasonDestroyed)
+	}
+}
@@ -0,0 +1,184 @@
+package audioplayer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+)
+
+// CheckpointEvery is the amount of ticks between checkpoints
+var CheckpointEvery = 10
+
+// Checkpoint is in-flight play saved to disk, so it survives daemon restart
+//
+// Plays held while device clock is invalid are saved too; ContentURI is empty if there is no play in flight.
+type Checkpoint struct {
+	ContentURI string
+	TrackID    string
+	PlayingFor int
+	Content    resolver.Content
+	Held       []playerevents.PlayerEventTrackListened
+}
+
+func (p *AudioPlayer) WithCheckpoint(filename string) *AudioPlayer {
+	p.checkpointFile = filename
+	return p
+}
+
+// Restore loads checkpoint saved by previous daemon run
+//
+// Checkpoint is applied (or finalized) on the first tick after current content has been resolved
+func (p *AudioPlayer) Restore() error {
+	if p.checkpointFile == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(p.checkpointFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return fmt.Errorf("cannot read checkpoint: %w", err)
+	}
+
+	c := &Checkpoint{}
+	if err = json.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("cannot unmarshal checkpoint: %w", err)
+	}
+
+	slog.Info("restored checkpoint", "uri", c.ContentURI, "for", c.PlayingFor, "held", len(c.Held))
+
+	if len(c.Held) > 0 {
+		// uptime of previous run is unknown, held plays cannot be rebased
+		for _, e := range c.Held {
+			e.ClockInvalid = true
+			p.send(e)
+		}
+
+		c.Held = nil
+		p.checkpointSaved = true
+		if err = p.writeCheckpoint(*c); err != nil {
+			return err
+		}
+	}
+
+	if c.ContentURI != "" {
+		p.restored = c
+	}
+
+	return nil
+}
+
+// resume continues restored play if the same content is still playing, otherwise restored play is finalized
+func (p *AudioPlayer) resume() {
+	if p.restored == nil || p.State == StateStart || p.CurrentTrack.ContentURI == "" || !p.CurrentContent.Attempted {
+		return
+	}
+
+	c := p.restored
+	p.restored = nil
+
+	if c.ContentURI == p.CurrentTrack.ContentURI && p.State == StateExecuting {
+		p.CurrentTrack.PlayingFor = c.PlayingFor
+		p.CurrentContent.StartedAt = c.Content.StartedAt
+		p.CurrentContent.Rating = c.Content.Rating
+		p.CurrentTrack.Uptime = UnknownUptime
+		slog.Info("resumed play", "uri", c.ContentURI, "for", c.PlayingFor)
+		p.note("resumed from checkpoint after %d s", c.PlayingFor)
+		return
+	}
+
+	t := Track{ContentURI: c.ContentURI, TrackID: c.TrackID, PlayingFor: c.PlayingFor}
+	if !c.Content.Rating && c.Content.Valid() && c.PlayingFor > 2 && p.excluded(c.ContentURI, c.Content) == "" {
+		p.emit(played(c.Content, t, playerevents.EndReasonDestroyed), UnknownUptime)
+		slog.Info("sent to scrobbler as skipped", "uri", c.ContentURI, "restored", true)
+	}
+
+	p.remember(c.Content, t, playerevents.EndReasonDestroyed)
+}
+
+// checkpoint saves current play every CheckpointEvery ticks
+func (p *AudioPlayer) checkpoint() error {
+	if p.CurrentTrack.PlayingFor%CheckpointEvery != 0 {
+		return nil
+	}
+
+	return p.saveCheckpoint()
+}
+
+// saveCheckpoint writes current play and held plays to checkpoint file
+func (p *AudioPlayer) saveCheckpoint() error {
+	if p.checkpointFile == "" || p.State == StateStorageUnmounted {
+		return nil
+	}
+
+	c := Checkpoint{}
+	for _, h := range p.held {
+		c.Held = append(c.Held, h.event)
+	}
+
+	if p.CurrentContent.Valid() {
+		c.ContentURI = p.CurrentTrack.ContentURI
+		c.TrackID = p.CurrentTrack.TrackID
+		c.PlayingFor = p.CurrentTrack.PlayingFor
+		c.Content = *p.CurrentContent
+	}
+
+	return p.writeCheckpoint(c)
+}
+
+// writeCheckpoint replaces checkpoint file, checkpoint without play in flight and held plays is removed
+func (p *AudioPlayer) writeCheckpoint(c Checkpoint) error {
+	if c.ContentURI == "" && len(c.Held) == 0 {
+		return p.dropCheckpoint()
+	}
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("cannot marshal checkpoint: %w", err)
+	}
+
+	tmp := p.checkpointFile + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("cannot write checkpoint: %w", err)
+	}
+
+	if err = os.Rename(tmp, p.checkpointFile); err != nil {
+		return fmt.Errorf("cannot rename checkpoint: %w", err)
+	}
+
+	p.checkpointSaved = true
+
+	return nil
+}
+
+// dropCheckpoint removes checkpoint when play is over
+func (p *AudioPlayer) dropCheckpoint() error {
+	if p.checkpointFile == "" || !p.checkpointSaved {
+		return nil
+	}
+
+	if p.State == StateStorageUnmounted {
+		p.checkpointDeferred = true
+		return nil
+	}
+
+	if len(p.held) > 0 {
+		// held plays outlive current play
+		return p.saveCheckpoint()
+	}
+
+	p.checkpointSaved = false
+
+	if err := os.Remove(p.checkpointFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("cannot remove checkpoint: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,103 @@
+package audioplayer
+
+import (
+	"path"
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"testing"
+)
+
+func TestAudioPlayer_Restore(t *testing.T) {
+	content := resolver.Content{
+		Artist:    "artist",
+		Album:     "album",
+		Track:     "/data/mnt/internal/MUSIC/1.flac",
+		Duration:  100,
+		StartedAt: 12345,
+		Attempted: true,
+	}
+
+	tests := []struct {
+		name           string
+		uri            string
+		wantPlayingFor int
+		wantStartedAt  int64
+		wantEvents     int
+	}{
+		{
+			name:           "same track still playing, resumed",
+			uri:            "/data/mnt/internal/MUSIC/1.flac",
+			wantPlayingFor: 30,
+			wantStartedAt:  12345,
+			wantEvents:     0,
+		},
+		{
+			name:           "other track playing, restored play finalized as skip",
+			uri:            "/data/mnt/internal/MUSIC/2.flac",
+			wantPlayingFor: 0,
+			wantStartedAt:  0,
+			wantEvents:     1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := path.Join(t.TempDir(), "state")
+
+			saved := New().WithCheckpoint(filename).WithState(StateExecuting).WithCurrentTrack(content.Track, 30).WithContent(&content)
+			if err := saved.checkpoint(); err != nil {
+				t.Fatalf("checkpoint() error = %v", err)
+			}
+
+			emitter := make(chan playerevents.PlayerEvent, 1)
+			p := New().WithCheckpoint(filename).WithState(StateExecuting).WithCurrentTrack(tt.uri, 0).WithPlayerEventEmitter(emitter)
+			p.CurrentContent.Attempted = true
+			if err := p.Restore(); err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+
+			p.resume()
+
+			if p.CurrentTrack.PlayingFor != tt.wantPlayingFor {
+				t.Errorf("PlayingFor = %v, want %v", p.CurrentTrack.PlayingFor, tt.wantPlayingFor)
+			}
+
+			if p.CurrentContent.StartedAt != tt.wantStartedAt {
+				t.Errorf("StartedAt = %v, want %v", p.CurrentContent.StartedAt, tt.wantStartedAt)
+			}
+
+			if len(emitter) != tt.wantEvents {
+				t.Errorf("events = %v, want %v", len(emitter), tt.wantEvents)
+			}
+		})
+	}
+}
+
+func TestAudioPlayer_CheckpointListened(t *testing.T) {
+	filename := path.Join(t.TempDir(), "state")
+	uri := "/data/mnt/internal/MUSIC/1.flac"
+
+	p := newTestPlayer(&DumbResolver{}).WithCheckpoint(filename)
+	emitter := p.PlayerEventEmitter()
+
+	// listened at 5 s, crash before the next periodic checkpoint
+	playFor(t, p, uri, "TK_1", 6)
+
+	if len(emitter) != 1 {
+		t.Fatalf("events = %v, want 1", len(emitter))
+	}
+
+	restarted := newTestPlayer(&DumbResolver{}).WithPlayerEventEmitter(emitter).WithCheckpoint(filename)
+	if err := restarted.Restore(); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	playFor(t, restarted, uri, "TK_1", 6)
+
+	if !restarted.CurrentContent.Rating {
+		t.Errorf("Rating = false, want listen restored from checkpoint")
+	}
+
+	if len(emitter) != 1 {
+		t.Errorf("events = %v, want 1, listen must not be sent again", len(emitter))
+	}
+}
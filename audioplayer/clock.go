@@ -0,0 +1,124 @@
+package audioplayer
+
+import (
+	"log/slog"
+	"scrobbler/playerevents"
+	"time"
+)
+
+// ClockJumpTolerance is how far back device clock may go between ticks before it is considered changed
+var ClockJumpTolerance = time.Minute
+
+// HeldLimit is the amount of events held while device clock is invalid; oldest events are sent flagged after that
+var HeldLimit = 100
+
+// UnknownUptime is used for plays which started before daemon did, their timestamps cannot be rebased
+const UnknownUptime = time.Duration(-1)
+
+// heldEvent is waiting for device clock to be set
+type heldEvent struct {
+	event  playerevents.PlayerEventTrackListened
+	uptime time.Duration // player uptime when play started
+}
+
+// WithMinValidTime enables device clock validation; clock is invalid if it reports time before t
+//
+// Walkman clock is not set after battery drain, reporting dates like 20180101.
+func (p *AudioPlayer) WithMinValidTime(t time.Time) *AudioPlayer {
+	p.minValidTime = t
+	return p
+}
+
+func (p *AudioPlayer) clockChecked() bool {
+	return !p.minValidTime.IsZero()
+}
+
+// checkClock validates device clock, rebasing timestamps when clock becomes valid or jumps backwards
+func (p *AudioPlayer) checkClock(now time.Time) {
+	valid := !now.Before(p.minValidTime)
+
+	jumped := !p.lastNow.IsZero() && now.Before(p.lastNow.Add(-ClockJumpTolerance))
+	p.lastNow = now
+
+	if valid != p.clockValid {
+		slog.Info("device clock", "valid", valid, "now", now)
+	}
+
+	if !valid {
+		p.clockValid = false
+		return
+	}
+
+	if !p.clockValid || jumped {
+		p.rebase(now)
+	}
+
+	p.clockValid = true
+	p.flushHeld(now)
+}
+
+// rebased returns timestamp of play started at player uptime
+func (p *AudioPlayer) rebased(now time.Time, uptime time.Duration) int64 {
+	return now.Add(uptime - p.uptime).Unix()
+}
+
+// rebase fixes start time of current play using player uptime
+func (p *AudioPlayer) rebase(now time.Time) {
+	if p.CurrentContent.StartedAt == 0 || p.CurrentTrack.Uptime == UnknownUptime {
+		return
+	}
+
+	p.CurrentContent.StartedAt = p.rebased(now, p.CurrentTrack.Uptime)
+	slog.Debug("rebased current play", "startedAt", p.CurrentContent.StartedAt)
+}
+
+func (p *AudioPlayer) flushHeld(now time.Time) {
+	if len(p.held) == 0 {
+		return
+	}
+
+	for _, h := range p.held {
+		if h.uptime == UnknownUptime {
+			h.event.ClockInvalid = true
+		} else {
+			h.event.Content.StartedAt = p.rebased(now, h.uptime)
+		}
+
+		p.send(h.event)
+	}
+
+	p.held = nil
+
+	if err := p.saveCheckpoint(); err != nil {
+		slog.Error("cannot save checkpoint", "error", err.Error())
+	}
+}
+
+// holding checks if emitted plays are held
+func (p *AudioPlayer) holding() bool {
+	return p.clockChecked() && !p.clockValid
+}
+
+// emit sends play to scrobbler; plays are held while device clock is invalid, held plays are saved to checkpoint
+func (p *AudioPlayer) emit(e playerevents.PlayerEventTrackListened, uptime time.Duration) {
+	e.Mode = p.modes.mode
+
+	if !p.holding() {
+		p.send(e)
+		return
+	}
+
+	slog.Debug("device clock is invalid, holding event", "track", e.Content.Track)
+	p.held = append(p.held, heldEvent{event: e, uptime: uptime})
+
+	if len(p.held) > HeldLimit {
+		h := p.held[0]
+		p.held = p.held[1:]
+		h.event.ClockInvalid = true
+		p.send(h.event)
+	}
+
+	if err := p.saveCheckpoint(); err != nil {
+		slog.Error("cannot save held plays", "error", err.Error())
+	}
+}
@@ -0,0 +1,113 @@
+package audioplayer
+
+import (
+	"path"
+	"scrobbler/playerevents"
+	"testing"
+	"time"
+)
+
+// settableClock advances by one second on every call, like a ticking device clock
+type settableClock struct {
+	now time.Time
+}
+
+func (s *settableClock) Now() time.Time {
+	res := s.now
+	s.now = s.now.Add(time.Second)
+	return res
+}
+
+func (*settableClock) After(d time.Duration) <-chan time.Time {
+	panic("implement me")
+}
+
+func TestAudioPlayer_InvalidClock(t *testing.T) {
+	clock := &settableClock{now: time.Date(2018, 1, 1, 17, 28, 28, 0, time.UTC)}
+	p := newTestPlayer(&DumbResolver{}).WithClock(clock).WithMinValidTime(time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	emitter := p.PlayerEventEmitter()
+
+	playFor(t, p, "/data/mnt/internal/MUSIC/1.flac", "TK_1", 6)
+
+	if len(emitter) != 0 {
+		t.Fatalf("events = %d, want 0, event must be held until clock is set", len(emitter))
+	}
+
+	// clock has been set, 6 ticks since play started
+	clock.now = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tick(t, p, 1)
+
+	if len(emitter) != 1 {
+		t.Fatalf("events = %d, want 1", len(emitter))
+	}
+
+	e := (<-emitter).(playerevents.PlayerEventTrackListened)
+	want := time.Date(2024, 5, 1, 11, 59, 54, 0, time.UTC).Unix()
+	if e.Content.StartedAt != want {
+		t.Errorf("StartedAt = %v, want %v", time.Unix(e.Content.StartedAt, 0).UTC(), time.Unix(want, 0).UTC())
+	}
+
+	if e.ClockInvalid {
+		t.Errorf("ClockInvalid = true, want false")
+	}
+
+	if p.CurrentContent.StartedAt != want {
+		t.Errorf("current StartedAt = %v, want %v", time.Unix(p.CurrentContent.StartedAt, 0).UTC(), time.Unix(want, 0).UTC())
+	}
+}
+
+func TestAudioPlayer_HeldRestart(t *testing.T) {
+	tests := []struct {
+		name  string
+		ended bool // held play has ended before restart
+	}{
+		{
+			name: "listen held, same track playing after restart",
+		},
+		{
+			name:  "ended play held",
+			ended: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := path.Join(t.TempDir(), "state")
+			uri := "/data/mnt/internal/MUSIC/1.flac"
+			minValidTime := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+
+			p := newTestPlayer(&DumbResolver{}).WithClock(&settableClock{now: time.Date(2018, 1, 1, 17, 28, 28, 0, time.UTC)}).
+				WithMinValidTime(minValidTime).WithCheckpoint(filename)
+
+			playFor(t, p, uri, "TK_1", 6)
+			if tt.ended {
+				p.EndOfStream()
+				p.DestroyTrack("TK_1")
+			}
+
+			if len(p.PlayerEventEmitter()) != 0 {
+				t.Fatalf("events = %d, want 0 before restart", len(p.PlayerEventEmitter()))
+			}
+
+			// clock is still not set after restart
+			restarted := newTestPlayer(&DumbResolver{}).WithClock(&settableClock{now: time.Date(2018, 1, 1, 17, 40, 0, 0, time.UTC)}).
+				WithMinValidTime(minValidTime).WithCheckpoint(filename)
+			emitter := restarted.PlayerEventEmitter()
+			if err := restarted.Restore(); err != nil {
+				t.Fatalf("Restore() error = %v", err)
+			}
+
+			if len(emitter) != 1 {
+				t.Fatalf("events = %d, want 1 held play written after restart", len(emitter))
+			}
+
+			if e := (<-emitter).(playerevents.PlayerEventTrackListened); !e.ClockInvalid || e.Content.Track != uri {
+				t.Errorf("event = %v %v, want %v flagged with invalid clock", e.Content.Track, e.ClockInvalid, uri)
+			}
+
+			playFor(t, restarted, uri, "TK_1", 6)
+			if !tt.ended && len(emitter) != 0 {
+				t.Errorf("events = %d, want 0, held listen must not be sent again", len(emitter))
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package audioplayer
+
+import (
+	"scrobbler/history"
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"time"
+)
+
+// WithHistory makes player record finished plays to h
+func (p *AudioPlayer) WithHistory(h *history.History) *AudioPlayer {
+	p.history = h
+	return p
+}
+
+// remember adds finished play to history
+func (p *AudioPlayer) remember(c resolver.Content, t Track, reason playerevents.EndReason) {
+	if p.history == nil || t.ContentURI == "" || t.PlayingFor == 0 {
+		return
+	}
+
+	outcome := history.OutcomeDropped
+	switch {
+	case t.Excluded != "":
+		// excluded plays are never sent
+	case c.Rating:
+		outcome = history.OutcomeListened
+	case t.PlayingFor > 2 && (c.Valid() || !c.Attempted):
+		// unresolved play is sent as skipped when content arrives
+		outcome = history.OutcomeSkipped
+	}
+
+	p.history.Add(history.Play{
+		URI:         t.ContentURI,
+		Content:     c,
+		Start:       time.Unix(c.StartedAt, 0),
+		End:         p.clock.Now(),
+		ListenedFor: t.PlayingFor,
+		Outcome:     outcome,
+		Reason:      reason,
+	})
+}
@@ -0,0 +1,46 @@
+package audioplayer
+
+import (
+	"scrobbler/history"
+	"scrobbler/playerevents"
+	"testing"
+)
+
+func TestAudioPlayer_WithHistory(t *testing.T) {
+	h := history.New(10)
+	p := newTestPlayer(&DumbResolver{}).WithHistory(h)
+
+	playFor(t, p, "/data/mnt/internal/MUSIC/1.flac", "TK_1", 6)
+	p.EndOfStream()
+	p.DestroyTrack("TK_1")
+
+	playFor(t, p, "/data/mnt/internal/MUSIC/2.flac", "TK_2", 3)
+	p.Command(playerevents.EndReasonNext)
+	p.DestroyTrack("TK_2")
+
+	playFor(t, p, "/data/mnt/internal/MUSIC/3.flac", "TK_3", 1)
+	p.DestroyTrack("TK_3")
+	p.SetContentURI("/data/mnt/internal/MUSIC/4.flac")
+
+	want := []history.Play{
+		{URI: "/data/mnt/internal/MUSIC/1.flac", ListenedFor: 6, Outcome: history.OutcomeListened, Reason: playerevents.EndReasonEndOfStream},
+		{URI: "/data/mnt/internal/MUSIC/2.flac", ListenedFor: 3, Outcome: history.OutcomeSkipped, Reason: playerevents.EndReasonNext},
+		{URI: "/data/mnt/internal/MUSIC/3.flac", ListenedFor: 1, Outcome: history.OutcomeDropped, Reason: playerevents.EndReasonDestroyed},
+	}
+
+	got := h.Plays()
+	if len(got) != len(want) {
+		t.Fatalf("plays = %d, want %d", len(got), len(want))
+	}
+
+	for i, w := range want {
+		g := got[i]
+		if g.URI != w.URI || g.ListenedFor != w.ListenedFor || g.Outcome != w.Outcome || g.Reason != w.Reason {
+			t.Errorf("play %d = %v %v %v %v, want %v %v %v %v", i, g.URI, g.ListenedFor, g.Outcome, g.Reason, w.URI, w.ListenedFor, w.Outcome, w.Reason)
+		}
+
+		if g.End.Before(g.Start) {
+			t.Errorf("play %d ends before start: %v - %v", i, g.Start, g.End)
+		}
+	}
+}
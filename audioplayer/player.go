@@ -7,9 +7,12 @@ import (
 	"path"
 	"reflect"
 	"scrobbler/audioscrobbler"
+	"scrobbler/device"
+	"scrobbler/history"
 	"scrobbler/parser"
 	"scrobbler/playerevents"
 	"scrobbler/resolver"
+	"scrobbler/rules"
 	"strings"
 	"sync"
 	"time"
@@ -56,7 +59,7 @@ var StateByID = map[int]string{
 	StateIdle:             "OMX_StateIdle",
 }
 
-var BeepIgnore = "WM_BEEP"
+var BeepIgnore = parser.BeepURISubstring
 
 type Clock interface {
 	Now() time.Time
@@ -69,18 +72,22 @@ func (*realClock) Now() time.Time                         { return time.Now() }
 func (*realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
 
 type Track struct {
-	ContentURI string
-	PlayingFor int
-	TrackID    string
+	ContentURI  string
+	PlayingFor  int
+	TrackID     string
+	PreparedFor string        // SoundService track id this track was prepared for, see TrackQueue
+	Uptime      time.Duration // player uptime when play started, used to rebase StartedAt
+	Suspended   time.Duration // time spent in suspend during play, not listening time
+	Seeked      bool
+	Excluded    string // rule which keeps the play out of scrobbler
 }
 
 // AudioPlayer tracks audio player state by consuming log entries
 type AudioPlayer struct {
 	State                 int
 	StateBefore           int
-	CurrentTrack          *Track // currently playing file uri + playing duration
-	NextTrack             *Track
-	Preparing             bool              // if true, next event with content uri belongs to next track
+	CurrentTrack          *Track            // currently playing file uri + playing duration
+	Prepared              *TrackQueue       // tracks prepared for gapless playback
 	CurrentContent        *resolver.Content // database info for current track
 	consumer              chan parser.Event
 	emitter               chan playerevents.PlayerEvent
@@ -91,6 +98,38 @@ type AudioPlayer struct {
 	lock                  sync.Mutex
 	tickDuration          time.Duration
 	clock                 Clock
+	play                  int // current play number, incremented on every track change
+	requested             int // play number content was requested for
+	resolved              chan resolution
+	ended                 map[int]*endedPlay // plays which ended before their content was resolved
+	beeping               bool
+	beepTicks             int                 // ticks since beep started
+	beepTracks            map[string]struct{} // SoundService tracks created for beeps
+	interrupted           *interruptedPlay    // music play interrupted by beep
+	destroyed             *destroyedTrack     // music track destroyed, next uri isn't known yet
+	uptime                time.Duration       // sum of ticks, doesn't depend on device clock
+	minValidTime          time.Time
+	clockValid            bool
+	lastNow               time.Time
+	held                  []heldEvent // events waiting for device clock to be set
+	suspendThreshold      time.Duration
+	lastTick              time.Time
+	suspended             time.Duration          // total time spent in suspend
+	command               playerevents.EndReason // last player service call, reason for next destroy
+	history               *history.History
+	modes                 modeDetector
+	syncResolve           bool
+	queued                []playerevents.PlayerEventTrackListened // events waiting for storage to be mounted
+	checkpointDeferred    bool                                    // checkpoint must be removed after storage is mounted
+	cardUnmounted         bool
+	cardQueued            []playerevents.PlayerEventTrackListened // events of sd card tracks waiting for card to be mounted
+	rules                 *rules.Rules
+	trace                 *Trace   // current play
+	traces                []*Trace // finished plays, oldest first
+	traceSize             int
+	checkpointFile        string
+	checkpointSaved       bool
+	restored              *Checkpoint // play from previous daemon run, see Restore
 }
 
 func New() *AudioPlayer {
@@ -98,8 +137,7 @@ func New() *AudioPlayer {
 		State:          StateStart,
 		StateBefore:    StateStart,
 		CurrentTrack:   &Track{},
-		NextTrack:      &Track{},
-		Preparing:      false,
+		Prepared:       &TrackQueue{},
 		CurrentContent: &resolver.Content{},
 		consumer:       make(chan parser.Event),
 		divider:        2,
@@ -107,6 +145,12 @@ func New() *AudioPlayer {
 		tickDuration:   time.Second,
 		emitter:        make(chan playerevents.PlayerEvent),
 		clock:          &realClock{},
+		play:           1,
+		resolved:       make(chan resolution, 8),
+		ended:          map[int]*endedPlay{},
+		beepTracks:     map[string]struct{}{},
+		trace:          &Trace{Play: 1},
+		traceSize:      TraceSize,
 	}
 
 	return p
@@ -179,8 +223,11 @@ func (p *AudioPlayer) WithContent(c *resolver.Content) *AudioPlayer {
 }
 
 func (p *AudioPlayer) WithNextTrack(uri string, playingFor int) *AudioPlayer {
-	p.NextTrack.ContentURI = uri
-	p.NextTrack.PlayingFor = playingFor
+	p.Prepared.tracks = append(p.Prepared.tracks, &Track{
+		ContentURI:  uri,
+		PlayingFor:  playingFor,
+		PreparedFor: p.CurrentTrack.TrackID,
+	})
 	return p
 }
 
@@ -225,35 +272,68 @@ func (p *AudioPlayer) SetContentURI(uri string) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
+	if p.destroyed != nil {
+		p.destroyed.reason = p.endReason(uri, p.destroyed.reason)
+	}
+
+	p.flushDestroyed()
+
+	interrupted := p.interrupted != nil
+	if interrupted && p.restoreInterrupted(uri) {
+		return
+	}
+	p.beeping = false
+
 	// current track has NOT been destroyed
-	if p.Preparing && p.CurrentTrack.ContentURI != "" {
-		p.NextTrack.ContentURI = uri
-		p.Preparing = false
+	if p.CurrentTrack.ContentURI != "" && p.Prepared.Fill(uri) {
 		return
 	}
 
-	p.CurrentTrack.ContentURI = uri
+	// nothing is playing, preparation was about this track
+	p.Prepared.DropPending()
+
+	// track has not been destroyed, e.g. log has no SoundService tracks; play ends when next uri is opened,
+	// play interrupted by beep has been finalized by restoreInterrupted
+	if !interrupted && p.CurrentTrack.ContentURI != uri && p.CurrentTrack.PlayingFor > 0 {
+		p.finish(p.endReason(uri, p.command))
+	}
+
 	p.CurrentTrack.PlayingFor = 0
+	if p.CurrentTrack.ContentURI == uri {
+		return
+	}
+
+	p.CurrentTrack.ContentURI = uri
+	p.modes.started(uri)
+	p.newPlay()
 }
 
-// Stop doesn't send listened events, only Destroy does
-//
-// bad case:
-// - loop track
-// - seek more than half of the track, so it won't be "listened"
-// track doesn't get destroyed, no "skipped" event fired
+// Stop doesn't send events, play must be finalized with finish before
 func (p *AudioPlayer) Stop() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 
 	p.CurrentTrack.PlayingFor = 0
-	p.CurrentContent.Rating = false
-	p.CurrentContent.StartedAt = 0
-	p.CurrentContent.Attempted = false
 
-	if p.NextTrack.ContentURI != "" {
-		p.CurrentTrack.ContentURI = p.NextTrack.ContentURI
+	if err := p.dropCheckpoint(); err != nil {
+		slog.Error("stop", "error", err.Error())
 	}
+
+	// gapless transition, same SoundService track continues with prepared content
+	if t := p.Prepared.Pop(p.CurrentTrack.TrackID); t != nil {
+		p.CurrentTrack.ContentURI = t.ContentURI
+		p.modes.started(t.ContentURI)
+	}
+
+	p.newPlay()
+}
+
+// Prepare marks that next content uri belongs to track following current one
+func (p *AudioPlayer) Prepare() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.Prepared.Prepare(p.CurrentTrack.TrackID)
 }
 
 func (p *AudioPlayer) Close() {
@@ -265,46 +345,227 @@ func (p *AudioPlayer) Close() {
 //
 // At this point current track has been destroyed, there is no info about prepared track yet
 func (p *AudioPlayer) DestroyTrack(s string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
 	slog.Debug("destroyed track %s", "track", s)
-	if !p.CurrentContent.Rating && p.CurrentContent.Valid() && p.CurrentTrack.PlayingFor > 2 {
-		e := playerevents.PlayerEventTrackListened{Content: *p.CurrentContent}
-		p.emitter <- e
-		slog.Info("sent to scrobbler as skipped", "uri", p.CurrentTrack.ContentURI)
+	if p.beepDestroyed(s) {
+		return
 	}
 
-	p.CurrentContent = &resolver.Content{
-		Artist:         "",
-		Album:          "",
-		Track:          "",
-		TrackNumber:    "",
-		Duration:       0,
-		Rating:         false,
-		StartedAt:      0,
-		MusicBrainzTID: "",
-		Attempted:      false,
+	reason := p.command
+	if reason == playerevents.EndReasonNone {
+		reason = playerevents.EndReasonDestroyed
 	}
 
+	// player destroys track before it opens next uri, which may be a beep; play is finalized once next uri is known
+	if s == p.CurrentTrack.TrackID && p.CurrentTrack.ContentURI != "" {
+		p.flushDestroyed()
+		p.destroyed = &destroyedTrack{trackID: s, reason: reason}
+		return
+	}
+
+	p.destroy(s, reason)
+}
+
+// destroy finalizes current play after destruction of track s
+func (p *AudioPlayer) destroy(s string, reason playerevents.EndReason) {
+	p.finish(reason)
+
 	if p.CurrentTrack.TrackID == s {
 		p.CurrentTrack.TrackID = ""
 		p.CurrentTrack.ContentURI = ""
 		p.CurrentTrack.PlayingFor = 0
 	}
 
-	if p.NextTrack.TrackID == s {
-		p.NextTrack.TrackID = ""
-		p.NextTrack.ContentURI = ""
-		p.NextTrack.PlayingFor = 0
+	// track is gone, so is everything prepared for it
+	p.Prepared.Drop(s)
+
+	p.newPlay()
+
+	if err := p.dropCheckpoint(); err != nil {
+		slog.Error("destroy", "error", err.Error())
+	}
+}
+
+// played makes scrobbler event for content played on track
+func played(c resolver.Content, t Track, reason playerevents.EndReason) playerevents.PlayerEventTrackListened {
+	e := playerevents.PlayerEventTrackListened{
+		Content:     c,
+		TrackID:     t.TrackID,
+		ListenedFor: t.PlayingFor,
+		Reason:      reason,
+		Seeked:      t.Seeked,
+		External:    device.IsExternal(t.ContentURI),
+	}
+
+	if c.Duration > 0 {
+		e.Fraction = float64(t.PlayingFor) / float64(c.Duration)
+	}
+
+	return e
+}
+
+// finish sends current play to scrobbler as skipped if it hasn't been listened and records it to history
+func (p *AudioPlayer) finish(reason playerevents.EndReason) {
+	playingFor := p.CurrentTrack.PlayingFor
+	if reason == playerevents.EndReasonUnmount && playingFor > 0 {
+		p.note("storage unmounted")
+	}
+
+	switch {
+	case playingFor == 0:
+	case p.CurrentContent.Rating:
+		p.note("ended (%s) after %d s", reason, playingFor)
+	case p.CurrentTrack.Excluded != "":
+		p.decide(p.play, VerdictNotSent, "ended (%s) after %d s, excluded by rule %s", reason, playingFor, p.CurrentTrack.Excluded)
+	case playingFor <= 2:
+		p.decide(p.play, VerdictNotSent, "ended (%s) after %d s, plays shorter than 3 s are ignored", reason, playingFor)
+	case p.CurrentContent.Valid():
+		p.emit(played(*p.CurrentContent, *p.CurrentTrack, reason), p.CurrentTrack.Uptime)
+		slog.Info("sent to scrobbler as skipped", "uri", p.CurrentTrack.ContentURI, "for", p.CurrentTrack.PlayingFor, "reason", reason)
+		p.decide(p.play, VerdictSkipped, "ended (%s) after %d s, below %d s threshold, sent as skipped", reason, playingFor, p.minimumListenDuration)
+	case !p.CurrentContent.Attempted && p.requested == p.play:
+		// content is still being resolved, decide when it arrives
+		p.ended[p.play] = &endedPlay{
+			track:     *p.CurrentTrack,
+			startedAt: p.CurrentContent.StartedAt,
+			reason:    reason,
+		}
+		p.note("ended (%s) after %d s before content was resolved, decision postponed", reason, playingFor)
+	default:
+		p.decide(p.play, VerdictNotSent, "ended (%s) after %d s, content is missing %s", reason, playingFor, strings.Join(p.CurrentContent.Missing(), ", "))
+	}
+
+	if p.CurrentTrack.PlayingFor > 0 {
+		p.modes.ended(p.CurrentTrack.ContentURI, *p.CurrentContent, reason)
+	}
+
+	p.remember(*p.CurrentContent, *p.CurrentTrack, reason)
+}
+
+// endReason tells why current play ends when uri is opened next
+//
+// Player service call is the reason if there was one. Previous button call hasn't been seen in captures,
+// so play ended without a call is taken as ended by previous button when uri is the one played before it.
+func (p *AudioPlayer) endReason(uri string, reason playerevents.EndReason) playerevents.EndReason {
+	if reason != playerevents.EndReasonNone && reason != playerevents.EndReasonDestroyed {
+		return reason
 	}
+
+	if uri != p.CurrentTrack.ContentURI && uri == p.modes.previous {
+		return playerevents.EndReasonPrevious
+	}
+
+	return playerevents.EndReasonDestroyed
+}
+
+// Command records player service call which is going to end current play
+func (p *AudioPlayer) Command(reason playerevents.EndReason) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.command = reason
+}
+
+// Seek marks current play as seeked; player seeks to start position right after track creation, that is ignored
+func (p *AudioPlayer) Seek() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.CurrentTrack.PlayingFor > 0 {
+		p.CurrentTrack.Seeked = true
+	}
+}
+
+// TrackSequence resets play mode detection, player has got new list of tracks
+func (p *AudioPlayer) TrackSequence() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.modes.sequence()
 }
 
 // CreateTrack event happens right before track starts playing
 // so let's assume it is related to current track
 func (p *AudioPlayer) CreateTrack(s string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.beeping {
+		p.beepTracks[s] = struct{}{}
+		p.note("beep track %s ignored", s)
+		return
+	}
+
+	p.flushDestroyed()
 	p.CurrentTrack.TrackID = s
+	p.requestResolve()
 }
 
 var ErrUnknownEvent = errors.New("unknown event")
 
+// Handle applies parser event to player
+func (p *AudioPlayer) Handle(event parser.Event) error {
+	slog.Debug("player event in", "event", reflect.TypeOf(event).String(), "data", fmt.Sprintf("%+v", event))
+
+	switch event.(type) {
+	case parser.EventPlayerStateChange:
+		ee := event.(parser.EventPlayerStateChange)
+		before := State[ee.Before]
+		after := State[ee.After]
+		return p.SetState(before, after)
+	case parser.EventStorageUnmounting:
+		ee := event.(parser.EventStorageUnmounting)
+		if ee.Storage != parser.StorageInternal {
+			p.cardUnmounting(ee.Storage)
+			return nil
+		}
+
+		err := p.SetState(p.StateBefore, StateStorageUnmounted)
+		p.lock.Lock()
+		p.flushDestroyed()
+		p.finish(playerevents.EndReasonUnmount)
+		p.lock.Unlock()
+		p.Stop()
+		return err
+	case parser.EventStorageMounted:
+		ee := event.(parser.EventStorageMounted)
+		if ee.Storage != parser.StorageInternal {
+			p.cardMounted(ee.Storage)
+			return nil
+		}
+
+		return p.SetState(p.StateBefore, StateStorageMounted)
+	case parser.EventContentURI:
+		ee := event.(parser.EventContentURI)
+		p.SetContentURI(ee.URI)
+	case parser.EventEndOfStream:
+		p.EndOfStream()
+	case parser.EventNextTrack:
+		p.Command(playerevents.EndReasonNext)
+	case parser.EventSeek:
+		p.Seek()
+	case parser.EventTrackSequence:
+		p.TrackSequence()
+	case parser.EventBeep:
+		ee := event.(parser.EventBeep)
+		p.Beep(ee.URI)
+	case parser.EventPreparing:
+		p.Prepare()
+	case parser.EventTrackDestroyed:
+		ee := event.(parser.EventTrackDestroyed)
+		p.DestroyTrack(ee.TrackID)
+	case parser.EventTrackCreated:
+		ee := event.(parser.EventTrackCreated)
+		p.CreateTrack(ee.TrackID)
+	default:
+		return errors.Join(ErrUnknownEvent, errors.New(reflect.TypeOf(event).String()))
+	}
+
+	return nil
+}
+
 func (p *AudioPlayer) Consume(stop chan struct{}, errCh chan error) {
 	ticker := time.NewTicker(p.tickDuration)
 	defer ticker.Stop()
@@ -314,34 +575,12 @@ Consume:
 		select {
 
 		case event := <-p.consumer:
-			slog.Debug("player event in", "event", reflect.TypeOf(event).String(), "data", fmt.Sprintf("%+v", event))
-
-			switch event.(type) {
-			case parser.EventPlayerStateChange:
-				ee := event.(parser.EventPlayerStateChange)
-				before := State[ee.Before]
-				after := State[ee.After]
-				errCh <- p.SetState(before, after)
-			case parser.EventStorageUnmounting:
-				errCh <- p.SetState(p.StateBefore, StateStorageUnmounted)
-				p.Stop()
-			case parser.EventStorageMounted:
-				errCh <- p.SetState(p.StateBefore, StateStorageMounted)
-			case parser.EventContentURI:
-				ee := event.(parser.EventContentURI)
-				p.SetContentURI(ee.URI)
-			case parser.EventEndOfStream:
-				p.Stop()
-			case parser.EventPreparing:
-				p.Preparing = true
-			case parser.EventTrackDestroyed:
-				ee := event.(parser.EventTrackDestroyed)
-				p.DestroyTrack(ee.TrackID)
-			case parser.EventTrackCreated:
-				ee := event.(parser.EventTrackCreated)
-				p.CreateTrack(ee.TrackID)
-			default:
-				errCh <- errors.Join(ErrUnknownEvent, errors.New(reflect.TypeOf(event).String()))
+			if err := p.Handle(event); err != nil {
+				errCh <- err
+			}
+		case r := <-p.resolved:
+			if err := p.ApplyResolution(r); err != nil {
+				errCh <- err
 			}
 		case <-ticker.C:
 			if err2 := p.Tick(); err2 != nil {
@@ -371,6 +610,11 @@ func (p *AudioPlayer) SetState(before int, after int) error {
 	if after == StateStorageUnmounted || after == StateStorageMounted {
 		p.StateBefore = p.State
 		p.State = after
+
+		if after == StateStorageMounted {
+			p.mounted()
+		}
+
 		return nil
 	}
 
@@ -385,7 +629,7 @@ func (p *AudioPlayer) SetState(before int, after int) error {
 	}
 
 	if after == StateLoaded {
-		p.Preparing = false
+		p.Prepared.DropPending()
 	}
 
 	p.StateBefore = p.State
@@ -396,20 +640,42 @@ func (p *AudioPlayer) SetState(before int, after int) error {
 
 // Tick ticks every second
 //
-// If you change tracks faster than one per second, only last one will be recorded
+// Content is resolved on track change, see ApplyResolution; play time is counted while content is resolved,
+// listen is not sent until content arrives
 func (p *AudioPlayer) Tick() error {
-	var err error
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.uptime += p.tickDuration
+
+	var now time.Time
+	if p.clockChecked() || p.suspendThreshold > 0 {
+		now = p.clock.Now()
+		valid := p.clockValid
+		if p.clockChecked() {
+			p.checkClock(now)
+		}
+
+		if p.suspendThreshold > 0 {
+			p.checkSuspend(now, valid != p.clockValid)
+		}
+	}
 
-	if !p.CurrentContent.Attempted && p.CurrentTrack.ContentURI != "" {
-		slog.Debug("resolving", "contentURI", p.CurrentTrack.ContentURI)
-		if p.CurrentContent, err = p.Resolve(p.CurrentTrack.ContentURI); err != nil {
-			p.CurrentContent.Attempted = true
-			return err
+	p.requestResolve()
+	p.resume()
+
+	if p.beeping {
+		p.beepTicks++
+		if p.beepTicks > BeepTimeout {
+			p.beepTimedOut()
 		}
+	}
 
-		p.CurrentContent.Attempted = true
-		p.minimumListenDuration = int(p.CurrentContent.Duration) / p.divider
-		p.CurrentContent.Rating = false
+	if p.destroyed != nil {
+		p.destroyed.ticks++
+		if p.destroyed.ticks > DestroyGrace {
+			p.flushDestroyed()
+		}
 	}
 
 	track := ""
@@ -419,12 +685,17 @@ func (p *AudioPlayer) Tick() error {
 
 	slog.Debug("status", "track", path.Base(p.CurrentTrack.ContentURI), "contentTitle", track, "elapsed", p.CurrentTrack.PlayingFor, "state", StateByID[p.State], "min", p.minimumListenDuration)
 
-	if p.State != StateExecuting {
+	if p.State != StateExecuting || p.beeping || p.destroyed != nil {
 		return nil
 	}
 
 	if p.CurrentContent.StartedAt == 0 {
-		p.CurrentContent.StartedAt = p.clock.Now().Unix()
+		if now.IsZero() {
+			now = p.clock.Now()
+		}
+
+		p.CurrentContent.StartedAt = now.Unix()
+		p.CurrentTrack.Uptime = p.uptime
 	}
 
 	p.CurrentTrack.PlayingFor++
@@ -434,13 +705,25 @@ func (p *AudioPlayer) Tick() error {
 		return nil
 	}
 
+	if p.CurrentTrack.Excluded != "" {
+		return nil
+	}
+
 	if (p.CurrentTrack.PlayingFor >= p.minimumListenDuration) && !p.CurrentContent.Rating {
 		p.CurrentContent.Rating = true
-		event := playerevents.PlayerEventTrackListened{Content: *p.CurrentContent}
-		p.emitter <- event
+		p.emit(played(*p.CurrentContent, *p.CurrentTrack, playerevents.EndReasonNone), p.CurrentTrack.Uptime)
+		p.decide(p.play, VerdictListened, "listened for %d s, %d s required, sent as listened", p.CurrentTrack.PlayingFor, p.minimumListenDuration)
 
 		slog.Info("sent to scrobbler", "track", p.CurrentContent.Track, "listened", p.CurrentContent.Rating, "for", p.CurrentTrack.PlayingFor)
+
+		if p.holding() {
+			// held listen has been saved with held plays, see emit
+			return nil
+		}
+
+		// resumed play must not be sent as listened again
+		return p.saveCheckpoint()
 	}
 
-	return nil
+	return p.checkpoint()
 }
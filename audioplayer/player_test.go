@@ -4,6 +4,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"os"
+	"scrobbler/history"
 	"scrobbler/parser"
 	"scrobbler/playerevents"
 	"scrobbler/resolver"
@@ -75,6 +76,36 @@ func (*staticClock) After(d time.Duration) <-chan time.Time {
 	panic("implement me")
 }
 
+// newTestPlayer creates playing player with static clock, emitted events are buffered
+func newTestPlayer(r resolver.Resolver) *AudioPlayer {
+	return New().WithResolver(r).WithClock(&staticClock{}).WithPlayerEventEmitter(make(chan playerevents.PlayerEvent, 10)).
+		WithState(StateExecuting)
+}
+
+// playFor opens uri as track trackID, applies resolved content and plays it for ticks
+func playFor(t *testing.T, p *AudioPlayer, uri string, trackID string, ticks int) {
+	t.Helper()
+
+	p.SetContentURI(uri)
+	p.CreateTrack(trackID)
+	if err := p.ApplyResolution(<-p.resolved); err != nil {
+		t.Fatalf("ApplyResolution() error = %v", err)
+	}
+
+	tick(t, p, ticks)
+}
+
+// tick ticks player n times
+func tick(t *testing.T, p *AudioPlayer, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		if err := p.Tick(); err != nil {
+			t.Fatalf("Tick() error = %v", err)
+		}
+	}
+}
+
 func TestAudioPlayer_Consume(t *testing.T) {
 	type fields struct {
 		AudioPlayer *AudioPlayer
@@ -105,7 +136,7 @@ func TestAudioPlayer_Consume(t *testing.T) {
 			name: "storage unmounted",
 			fields: fields{
 				AudioPlayer: New().WithState(StateExecuting).WithResolver(&DumbResolver{}),
-				Events:      []parser.Event{parser.EventStorageUnmounting{}},
+				Events:      []parser.Event{parser.EventStorageUnmounting{Storage: parser.StorageInternal}},
 			},
 			want: want{
 				Player: New().WithState(StateStorageUnmounted),
@@ -155,17 +186,21 @@ func TestAudioPlayer_Consume(t *testing.T) {
 				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/2.flac", 0).WithState(StatePause),
 				Errors: []error{},
 				PlayerEvents: []playerevents.PlayerEvent{
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:         "artist",
-						Album:          "album",
-						Track:          "/data/mnt/internal/MUSIC/1.flac",
-						TrackNumber:    "1",
-						Duration:       10,
-						Rating:         true,
-						StartedAt:      12345,
-						MusicBrainzTID: "",
-						Attempted:      true,
-					}},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:         "artist",
+							Album:          "album",
+							Track:          "/data/mnt/internal/MUSIC/1.flac",
+							TrackNumber:    "1",
+							Duration:       10,
+							Rating:         true,
+							StartedAt:      12345,
+							MusicBrainzTID: "",
+							Attempted:      true,
+						},
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
 				},
 			},
 		},
@@ -179,36 +214,53 @@ func TestAudioPlayer_Consume(t *testing.T) {
 				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/71. Pop Team Epicrimson.mp3", 0).WithState(StateExecuting),
 				Errors: []error{},
 				PlayerEvents: []playerevents.PlayerEvent{
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/71. Pop Team Epicrimson.mp3",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12345,
-						Attempted:   true,
-					}},
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/71. Pop Team Epicrimson.mp3",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12346,
-						Attempted:   true,
-					}},
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/71. Pop Team Epicrimson.mp3",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12347,
-						Attempted:   true,
-					}},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/71. Pop Team Epicrimson.mp3",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12345,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_293_PKT_131072_QUE_5_8",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/71. Pop Team Epicrimson.mp3",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12346,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_293_PKT_131072_QUE_5_8",
+						ListenedFor: 5,
+						Fraction:    0.5,
+						Mode:        playerevents.PlayMode{Repeat: playerevents.RepeatOne},
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/71. Pop Team Epicrimson.mp3",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12347,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_293_PKT_131072_QUE_5_8",
+						ListenedFor: 5,
+						Fraction:    0.5,
+						Mode:        playerevents.PlayMode{Repeat: playerevents.RepeatOne},
+					},
 				},
 			},
 		},
@@ -221,17 +273,21 @@ func TestAudioPlayer_Consume(t *testing.T) {
 			want: want{
 				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/2.flac", 0).WithState(StateExecuting),
 				Errors: []error{},
-				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{Content: resolver.Content{
-					Artist:         "artist",
-					Album:          "album",
-					Track:          "/data/mnt/internal/MUSIC/2.flac",
-					TrackNumber:    "1",
-					Duration:       10,
-					Rating:         true,
-					StartedAt:      12345,
-					MusicBrainzTID: "",
-					Attempted:      true,
-				}}},
+				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{
+					Content: resolver.Content{
+						Artist:         "artist",
+						Album:          "album",
+						Track:          "/data/mnt/internal/MUSIC/2.flac",
+						TrackNumber:    "1",
+						Duration:       10,
+						Rating:         true,
+						StartedAt:      12345,
+						MusicBrainzTID: "",
+						Attempted:      true,
+					},
+					ListenedFor: 5,
+					Fraction:    0.5,
+				}},
 			},
 		},
 		{
@@ -243,26 +299,36 @@ func TestAudioPlayer_Consume(t *testing.T) {
 			want: want{
 				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/03.mp3", 0).WithState(StateExecuting),
 				Errors: []error{},
-				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{Content: resolver.Content{
-					Artist:      "artist",
-					Album:       "album",
-					Track:       "/data/mnt/internal/MUSIC/01.mp3",
-					TrackNumber: "1",
-					Duration:    10,
-					Rating:      true,
-					StartedAt:   12345,
-					Attempted:   true,
-				}},
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
+				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{
+					Content: resolver.Content{
 						Artist:      "artist",
 						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/03.mp3",
+						Track:       "/data/mnt/internal/MUSIC/01.mp3",
 						TrackNumber: "1",
 						Duration:    10,
 						Rating:      true,
-						StartedAt:   12346,
+						StartedAt:   12345,
 						Attempted:   true,
-					}}},
+					},
+					TrackID:     "TK_MUSIC_PID_307_PKT_131072_QUE_5_3",
+					ListenedFor: 5,
+					Fraction:    0.5,
+				},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/03.mp3",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12346,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_307_PKT_131072_QUE_5_4",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					}},
 			},
 		},
 		{
@@ -274,16 +340,20 @@ func TestAudioPlayer_Consume(t *testing.T) {
 			want: want{
 				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/1.flac", 0).WithState(StateExecuting),
 				Errors: []error{},
-				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{Content: resolver.Content{
-					Artist:      "artist",
-					Album:       "album",
-					Track:       "/data/mnt/internal/MUSIC/1.flac",
-					TrackNumber: "1",
-					Duration:    10,
-					Rating:      true,
-					StartedAt:   12345,
-					Attempted:   true,
-				}}},
+				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{
+					Content: resolver.Content{
+						Artist:      "artist",
+						Album:       "album",
+						Track:       "/data/mnt/internal/MUSIC/1.flac",
+						TrackNumber: "1",
+						Duration:    10,
+						Rating:      true,
+						StartedAt:   12345,
+						Attempted:   true,
+					},
+					ListenedFor: 5,
+					Fraction:    0.5,
+				}},
 			},
 		},
 		{
@@ -295,16 +365,20 @@ func TestAudioPlayer_Consume(t *testing.T) {
 			want: want{
 				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/99.mp3", 0).WithState(StateExecuting),
 				Errors: []error{},
-				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{Content: resolver.Content{
-					Artist:      "artist",
-					Album:       "album",
-					Track:       "/data/mnt/internal/MUSIC/99.mp3",
-					TrackNumber: "1",
-					Duration:    10,
-					Rating:      true,
-					StartedAt:   12345,
-					Attempted:   true,
-				}}},
+				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{
+					Content: resolver.Content{
+						Artist:      "artist",
+						Album:       "album",
+						Track:       "/data/mnt/internal/MUSIC/99.mp3",
+						TrackNumber: "1",
+						Duration:    10,
+						Rating:      true,
+						StartedAt:   12345,
+						Attempted:   true,
+					},
+					ListenedFor: 5,
+					Fraction:    0.5,
+				}},
 			},
 		},
 		{
@@ -328,6 +402,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12345,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_312_PKT_131072_QUE_3_1",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -340,6 +417,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12346,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_312_PKT_131072_QUE_3_2",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -352,6 +432,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12347,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_312_PKT_131072_QUE_5_3",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -364,6 +447,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12348,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_312_PKT_131072_QUE_5_4",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -376,6 +462,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12349,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_312_PKT_131072_QUE_5_5",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -388,6 +477,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12350,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_312_PKT_131072_QUE_5_6",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 				},
 			},
@@ -413,6 +505,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12345,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_5_1",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -425,6 +520,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12346,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_5_2",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -437,6 +535,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12347,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_5_3",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -449,6 +550,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12348,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_5_4",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -461,6 +565,9 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12349,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_3_5",
+						ListenedFor: 5,
+						Fraction:    0.5,
 					},
 					playerevents.PlayerEventTrackListened{
 						Content: resolver.Content{
@@ -473,6 +580,124 @@ func TestAudioPlayer_Consume(t *testing.T) {
 							StartedAt:   12350,
 							Attempted:   true,
 						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_3_6",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
+				},
+			},
+		},
+		{
+			name: "regular play, 6 songs, no loop, shuffle, one album",
+			fields: fields{
+				AudioPlayer: New().WithResolver(AlbumResolver{
+					"/data/mnt/internal/MUSIC/12 Sadeness (Meditation).ape":  "12",
+					"/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac": "7",
+					"/data/mnt/internal/MUSIC/Snowflake.flac":                "3",
+					"/data/mnt/internal/MUSIC/01. Thunderstruck.mp3":         "1",
+					"/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf":       "2",
+					"/data/mnt/internal/MUSIC/Paddy Fahey's.dsf":             "9",
+				}).WithClock(&staticClock{}).WithTickDuration(time.Millisecond * 10),
+				Filename: "test/regular_play_6_songs_no_loop_shuffle.log",
+			},
+			want: want{
+				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf", 0).WithState(StatePause),
+				Errors: []error{},
+				PlayerEvents: []playerevents.PlayerEvent{
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/12 Sadeness (Meditation).ape",
+							TrackNumber: "12",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12345,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_5_1",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac",
+							TrackNumber: "7",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12346,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_5_2",
+						ListenedFor: 5,
+						Fraction:    0.5,
+						Mode:        playerevents.PlayMode{Shuffle: true},
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/Snowflake.flac",
+							TrackNumber: "3",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12347,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_5_3",
+						ListenedFor: 5,
+						Fraction:    0.5,
+						Mode:        playerevents.PlayMode{Shuffle: true},
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/01. Thunderstruck.mp3",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12348,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_5_4",
+						ListenedFor: 5,
+						Fraction:    0.5,
+						Mode:        playerevents.PlayMode{Shuffle: true},
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf",
+							TrackNumber: "2",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12349,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_3_5",
+						ListenedFor: 5,
+						Fraction:    0.5,
+						Mode:        playerevents.PlayMode{Shuffle: true},
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/Paddy Fahey's.dsf",
+							TrackNumber: "9",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12350,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_292_PKT_131072_QUE_3_6",
+						ListenedFor: 5,
+						Fraction:    0.5,
+						Mode:        playerevents.PlayMode{Shuffle: true},
 					},
 				},
 			},
@@ -487,36 +712,52 @@ func TestAudioPlayer_Consume(t *testing.T) {
 				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/01 - Resurrection.mp3", 0).WithState(StateExecuting),
 				Errors: nil,
 				PlayerEvents: []playerevents.PlayerEvent{
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12345,
-						Attempted:   true,
-					}},
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/03 - Bucovina [Haaksman & Haaksman Soca Bogle Mix] - Shantel.mp3",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      false,
-						StartedAt:   12346,
-						Attempted:   true,
-					}},
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/01 - Resurrection.mp3",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12347,
-						Attempted:   true,
-					}},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12345,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_307_PKT_131072_QUE_5_8",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/03 - Bucovina [Haaksman & Haaksman Soca Bogle Mix] - Shantel.mp3",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      false,
+							StartedAt:   12346,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_307_PKT_131072_QUE_5_9",
+						ListenedFor: 3,
+						Fraction:    0.3,
+						Reason:      playerevents.EndReasonNext,
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/01 - Resurrection.mp3",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12347,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_307_PKT_131072_QUE_5_10",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
 				},
 			},
 		},
@@ -529,16 +770,21 @@ func TestAudioPlayer_Consume(t *testing.T) {
 			want: want{
 				Player: New().WithState(StateStorageUnmounted),
 				PlayerEvents: []playerevents.PlayerEvent{
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/01 - Resurrection.mp3",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12345,
-						Attempted:   true,
-					}},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/01 - Resurrection.mp3",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12345,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_293_PKT_131072_QUE_5_1",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
 				},
 				Errors: []error{ErrStorageUnmounted, ErrStorageUnmounted, ErrStorageUnmounted, ErrStorageUnmounted, ErrStorageUnmounted, ErrStorageUnmounted},
 			},
@@ -552,16 +798,21 @@ func TestAudioPlayer_Consume(t *testing.T) {
 			want: want{
 				Player: New().WithState(StateExecuting).WithCurrentTrack("/data/mnt/internal/MUSIC/01 - Resurrection.mp3", 10),
 				Errors: nil,
-				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{Content: resolver.Content{
-					Artist:      "artist",
-					Album:       "album",
-					Track:       "/data/mnt/internal/MUSIC/01 - Resurrection.mp3",
-					TrackNumber: "1",
-					Duration:    10,
-					Rating:      true,
-					StartedAt:   12345,
-					Attempted:   true,
-				}}},
+				PlayerEvents: []playerevents.PlayerEvent{playerevents.PlayerEventTrackListened{
+					Content: resolver.Content{
+						Artist:      "artist",
+						Album:       "album",
+						Track:       "/data/mnt/internal/MUSIC/01 - Resurrection.mp3",
+						TrackNumber: "1",
+						Duration:    10,
+						Rating:      true,
+						StartedAt:   12345,
+						Attempted:   true,
+					},
+					TrackID:     "TK_MUSIC_PID_293_PKT_131072_QUE_5_2",
+					ListenedFor: 5,
+					Fraction:    0.5,
+				}},
 			},
 		},
 		{
@@ -574,26 +825,37 @@ func TestAudioPlayer_Consume(t *testing.T) {
 				Player: New().WithState(StateExecuting).WithCurrentTrack("/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf", 0),
 				Errors: nil,
 				PlayerEvents: []playerevents.PlayerEvent{
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12345,
-						Attempted:   true,
-					}},
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12346,
-						Attempted:   true,
-					}},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12345,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_311_PKT_131072_QUE_3_2",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12346,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_311_PKT_131072_QUE_3_3",
+						ListenedFor: 5,
+						Fraction:    0.5,
+						Mode:        playerevents.PlayMode{Repeat: playerevents.RepeatOne},
+					},
 				},
 			},
 		},
@@ -607,16 +869,21 @@ func TestAudioPlayer_Consume(t *testing.T) {
 				Player: New().WithCurrentTrack("/data/mnt/internal/MUSIC/01 - Resurrection.mp3", 0).WithState(StatePause),
 				Errors: nil,
 				PlayerEvents: []playerevents.PlayerEvent{
-					playerevents.PlayerEventTrackListened{Content: resolver.Content{
-						Artist:      "artist",
-						Album:       "album",
-						Track:       "/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac",
-						TrackNumber: "1",
-						Duration:    10,
-						Rating:      true,
-						StartedAt:   12345,
-						Attempted:   true,
-					}},
+					playerevents.PlayerEventTrackListened{
+						Content: resolver.Content{
+							Artist:      "artist",
+							Album:       "album",
+							Track:       "/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac",
+							TrackNumber: "1",
+							Duration:    10,
+							Rating:      true,
+							StartedAt:   12345,
+							Attempted:   true,
+						},
+						TrackID:     "TK_MUSIC_PID_311_PKT_131072_QUE_5_24",
+						ListenedFor: 5,
+						Fraction:    0.5,
+					},
 				},
 			},
 		},
@@ -690,9 +957,160 @@ func TestAudioPlayer_Consume(t *testing.T) {
 				t.Errorf("current track contentURI mismatsh: %s, want %s", tt.fields.AudioPlayer.CurrentTrack.ContentURI, tt.want.Player.CurrentTrack.ContentURI)
 			}
 
-			if !slices.Equal(tt.want.PlayerEvents, playerEvents) {
-				t.Errorf("player events not equal: %s",
-					cmp.Diff(tt.want.PlayerEvents, playerEvents))
+			if diff := cmp.Diff(tt.want.PlayerEvents, playerEvents, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("player events not equal: %s", diff)
+			}
+		})
+	}
+}
+
+func TestAudioPlayer_EndReason(t *testing.T) {
+	tests := []struct {
+		name string
+		end  func(p *AudioPlayer)
+		seek bool
+		want playerevents.PlayerEventTrackListened
+	}{
+		{
+			name: "next button",
+			end: func(p *AudioPlayer) {
+				p.Command(playerevents.EndReasonNext)
+				p.DestroyTrack("TK_MUSIC_PID_312_PKT_131072_QUE_5_3")
+			},
+			want: playerevents.PlayerEventTrackListened{TrackID: "TK_MUSIC_PID_312_PKT_131072_QUE_5_3", ListenedFor: 4, Fraction: 0.4, Reason: playerevents.EndReasonNext},
+		},
+		{
+			name: "track destroyed, seeked",
+			end: func(p *AudioPlayer) {
+				p.DestroyTrack("TK_MUSIC_PID_312_PKT_131072_QUE_5_3")
+			},
+			seek: true,
+			want: playerevents.PlayerEventTrackListened{TrackID: "TK_MUSIC_PID_312_PKT_131072_QUE_5_3", ListenedFor: 4, Fraction: 0.4, Reason: playerevents.EndReasonDestroyed, Seeked: true},
+		},
+		{
+			name: "end of stream",
+			end: func(p *AudioPlayer) {
+				p.EndOfStream()
+			},
+			want: playerevents.PlayerEventTrackListened{TrackID: "TK_MUSIC_PID_312_PKT_131072_QUE_5_3", ListenedFor: 4, Fraction: 0.4, Reason: playerevents.EndReasonEndOfStream},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlayer(&DumbResolver{})
+			emitter := p.PlayerEventEmitter()
+
+			playFor(t, p, "/data/mnt/internal/MUSIC/1.flac", "TK_MUSIC_PID_312_PKT_131072_QUE_5_3", 0)
+
+			// seek right after track creation is not a user seek
+			p.Seek()
+			for i := 0; i < 4; i++ {
+				tick(t, p, 1)
+
+				if tt.seek && i == 1 {
+					p.Seek()
+				}
+			}
+
+			tt.end(p)
+			// destroyed play is finalized once player opens next uri
+			p.SetContentURI("/data/mnt/internal/MUSIC/2.flac")
+
+			if len(emitter) != 1 {
+				t.Fatalf("events = %d, want 1", len(emitter))
+			}
+
+			e := (<-emitter).(playerevents.PlayerEventTrackListened)
+			if diff := cmp.Diff(tt.want, e, cmpopts.IgnoreFields(playerevents.PlayerEventTrackListened{}, "Content")); diff != "" {
+				t.Errorf("event mismatch: %s", diff)
+			}
+		})
+	}
+}
+
+func TestAudioPlayer_PreviousCapture(t *testing.T) {
+	type ended struct {
+		URI    string
+		Reason playerevents.EndReason
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		before   []string // uris played before capture, the last one is current
+		want     []ended
+	}{
+		{
+			name:     "previous track opened",
+			filename: "test/previous_track_manual.log",
+			before:   []string{"/data/mnt/internal/MUSIC/99.mp3"},
+			want: []ended{
+				{URI: "/data/mnt/internal/MUSIC/99.mp3", Reason: playerevents.EndReasonDestroyed},
+				{URI: "/data/mnt/internal/MUSIC/01.flac", Reason: playerevents.EndReasonPrevious},
+			},
+		},
+		{
+			name:     "previous once",
+			filename: "test/prev_once.log",
+			before:   []string{"/data/mnt/internal/MUSIC/1.flac", "/data/mnt/internal/MUSIC/2.flac"},
+			want: []ended{
+				{URI: "/data/mnt/internal/MUSIC/1.flac", Reason: playerevents.EndReasonDestroyed},
+				{URI: "/data/mnt/internal/MUSIC/2.flac", Reason: playerevents.EndReasonPrevious},
+			},
+		},
+		{
+			name:     "track not played before is not previous",
+			filename: "test/prev_once.log",
+			before:   []string{"/data/mnt/internal/MUSIC/2.flac"},
+			want: []ended{
+				{URI: "/data/mnt/internal/MUSIC/2.flac", Reason: playerevents.EndReasonDestroyed},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := history.New(10)
+			p := newTestPlayer(&DumbResolver{}).WithSyncResolve().WithHistory(h)
+
+			for _, uri := range tt.before {
+				playFor(t, p, uri, "", 3)
+			}
+
+			data, err := os.ReadFile(tt.filename)
+			if err != nil {
+				t.Fatalf("cannot read capture: %s", err.Error())
+			}
+
+			// captures have neither timestamps nor SoundService tracks, player ticks once per event
+			pp := parser.LogParser{}
+			for _, line := range strings.Split(string(data), "\n") {
+				event, err := pp.Event(line)
+				if err != nil {
+					t.Fatalf("Event() error = %v", err)
+				}
+
+				if event == nil {
+					continue
+				}
+
+				if err = p.Handle(event); err != nil {
+					t.Fatalf("Handle() error = %v", err)
+				}
+
+				if err = p.ApplyResolved(); err != nil {
+					t.Fatalf("ApplyResolved() error = %v", err)
+				}
+
+				tick(t, p, 1)
+			}
+
+			var got []ended
+			for _, play := range h.Plays() {
+				got = append(got, ended{URI: play.URI, Reason: play.Reason})
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("ended plays mismatch (-want +got):\n%s", diff)
 			}
 		})
 	}
@@ -0,0 +1,120 @@
+package audioplayer
+
+import (
+	"log/slog"
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"strconv"
+)
+
+// modeDetector guesses play mode from the order in which player opens content
+//
+// Only transitions made by player itself are taken into account, not the ones caused by next/previous buttons:
+//   - same content opened again is repeat one
+//   - content already played in current track sequence opened again is repeat all
+//   - next track from the same album which doesn't follow previous one by track number is shuffle,
+//     shuffled order may still put two tracks in a row, so shuffle is kept until new track sequence
+type modeDetector struct {
+	mode     playerevents.PlayMode
+	previous string           // uri of the last ended play
+	content  resolver.Content // content of the last ended play
+	natural  bool             // last play ended without user action
+	current  string           // uri of current play, empty if play hasn't started yet
+	compare  bool             // current content must be compared with previous one when resolved
+	played   map[string]struct{}
+}
+
+// sequence starts new track sequence, player got new list of tracks
+func (d *modeDetector) sequence() {
+	d.played = map[string]struct{}{}
+	d.natural = false
+
+	mode := d.mode
+	mode.Shuffle = false
+	if mode.Repeat == playerevents.RepeatAll {
+		mode.Repeat = playerevents.RepeatOff
+	}
+
+	d.set(mode)
+}
+
+// ended records play which has just ended
+func (d *modeDetector) ended(uri string, c resolver.Content, reason playerevents.EndReason) {
+	d.previous = uri
+	d.content = c
+	d.natural = reason == playerevents.EndReasonEndOfStream || reason == playerevents.EndReasonDestroyed
+	d.current = ""
+	d.compare = false
+}
+
+// started records play of uri, which follows the last ended play
+func (d *modeDetector) started(uri string) {
+	if d.current != "" {
+		d.current = uri
+		return
+	}
+
+	d.current = uri
+	if d.played == nil {
+		d.played = map[string]struct{}{}
+	}
+
+	_, seen := d.played[uri]
+	d.played[uri] = struct{}{}
+
+	if !d.natural || d.previous == "" {
+		return
+	}
+
+	mode := d.mode
+	switch {
+	case uri == d.previous:
+		mode.Repeat = playerevents.RepeatOne
+	case seen:
+		mode.Repeat = playerevents.RepeatAll
+	default:
+		if mode.Repeat == playerevents.RepeatOne {
+			mode.Repeat = playerevents.RepeatOff
+		}
+
+		d.compare = true
+	}
+
+	d.set(mode)
+}
+
+// resolved checks track order once current content is known
+func (d *modeDetector) resolved(c resolver.Content) {
+	if !d.compare {
+		return
+	}
+
+	d.compare = false
+
+	if c.Album == "" || c.Album != d.content.Album || c.Artist != d.content.Artist {
+		return
+	}
+
+	previous, err := strconv.Atoi(d.content.TrackNumber)
+	if err != nil {
+		return
+	}
+
+	current, err := strconv.Atoi(c.TrackNumber)
+	if err != nil || current == previous || current == previous+1 {
+		return
+	}
+
+	mode := d.mode
+	mode.Shuffle = true
+	d.set(mode)
+}
+
+func (d *modeDetector) set(mode playerevents.PlayMode) {
+	if mode == d.mode {
+		return
+	}
+
+	slog.Info("play mode changed", "shuffle", mode.Shuffle, "repeat", mode.Repeat)
+	d.mode = mode
+}
@@ -0,0 +1,106 @@
+package audioplayer
+
+import (
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"testing"
+)
+
+// AlbumResolver puts every track into one album, numbered by uri
+type AlbumResolver map[string]string
+
+func (a AlbumResolver) Resolve(uri string) (*resolver.Content, error) {
+	return &resolver.Content{
+		Artist:      "artist",
+		Album:       "album",
+		Track:       uri,
+		TrackNumber: a[uri],
+		Duration:    10,
+	}, nil
+}
+
+func Test_modeDetector(t *testing.T) {
+	type play struct {
+		uri    string
+		number string
+		reason playerevents.EndReason
+	}
+
+	eos := playerevents.EndReasonEndOfStream
+	next := playerevents.EndReasonNext
+
+	tests := []struct {
+		name     string
+		plays    []play
+		sequence bool // new track sequence after plays
+		want     playerevents.PlayMode
+	}{
+		{
+			name:  "linear",
+			plays: []play{{"1.flac", "1", eos}, {"2.flac", "2", eos}, {"3.flac", "3", eos}},
+			want:  playerevents.PlayMode{},
+		},
+		{
+			name:  "repeat one",
+			plays: []play{{"1.flac", "1", eos}, {"1.flac", "1", eos}},
+			want:  playerevents.PlayMode{Repeat: playerevents.RepeatOne},
+		},
+		{
+			name:  "track before current after previous button is not repeat",
+			plays: []play{{"1.flac", "1", eos}, {"2.flac", "2", playerevents.EndReasonPrevious}, {"1.flac", "1", eos}},
+			want:  playerevents.PlayMode{},
+		},
+		{
+			name:  "repeat one turned off",
+			plays: []play{{"1.flac", "1", eos}, {"1.flac", "1", eos}, {"2.flac", "2", eos}},
+			want:  playerevents.PlayMode{},
+		},
+		{
+			name:  "repeat all",
+			plays: []play{{"1.flac", "1", eos}, {"2.flac", "2", eos}, {"1.flac", "1", eos}},
+			want:  playerevents.PlayMode{Repeat: playerevents.RepeatAll},
+		},
+		{
+			name:  "shuffle",
+			plays: []play{{"1.flac", "1", eos}, {"7.flac", "7", eos}},
+			want:  playerevents.PlayMode{Shuffle: true},
+		},
+		{
+			name:  "skipped with next button is not shuffle",
+			plays: []play{{"1.flac", "1", next}, {"7.flac", "7", eos}},
+			want:  playerevents.PlayMode{},
+		},
+		{
+			name:  "shuffled tracks in a row",
+			plays: []play{{"1.flac", "1", eos}, {"7.flac", "7", eos}, {"8.flac", "8", eos}},
+			want:  playerevents.PlayMode{Shuffle: true},
+		},
+		{
+			name:     "shuffle turned off",
+			plays:    []play{{"1.flac", "1", eos}, {"7.flac", "7", eos}},
+			sequence: true,
+			want:     playerevents.PlayMode{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &modeDetector{}
+			d.sequence()
+
+			for _, p := range tt.plays {
+				c := resolver.Content{Artist: "artist", Album: "album", Track: p.uri, TrackNumber: p.number}
+				d.started(p.uri)
+				d.resolved(c)
+				d.ended(p.uri, c, p.reason)
+			}
+
+			if tt.sequence {
+				d.sequence()
+			}
+
+			if d.mode != tt.want {
+				t.Errorf("mode = %+v, want %+v", d.mode, tt.want)
+			}
+		})
+	}
+}
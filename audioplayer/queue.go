@@ -0,0 +1,89 @@
+package audioplayer
+
+// TrackQueue holds tracks prepared for gapless playback.
+//
+// SoundService keeps a gapless transition inside one track (TK_MUSIC_PID_..._QUE_n_m), so prepared
+// tracks are keyed by ID of the track that was playing when "Preparing next track." was logged.
+// Prepared track without content URI is a placeholder, waiting for next content URI event.
+type TrackQueue struct {
+	tracks []*Track
+}
+
+// Prepare adds a placeholder for track which will follow trackID
+func (q *TrackQueue) Prepare(trackID string) {
+	q.tracks = append(q.tracks, &Track{PreparedFor: trackID})
+}
+
+// Fill sets content uri on the oldest placeholder, returns false if there are no placeholders
+func (q *TrackQueue) Fill(uri string) bool {
+	for _, t := range q.tracks {
+		if t.ContentURI == "" {
+			t.ContentURI = uri
+			return true
+		}
+	}
+
+	return false
+}
+
+// Pending returns true if there is a placeholder waiting for content uri
+func (q *TrackQueue) Pending() bool {
+	for _, t := range q.tracks {
+		if t.ContentURI == "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// DropPending removes placeholders
+func (q *TrackQueue) DropPending() {
+	res := q.tracks[:0]
+	for _, t := range q.tracks {
+		if t.ContentURI != "" {
+			res = append(res, t)
+		}
+	}
+
+	q.tracks = res
+}
+
+// Pop removes and returns the oldest prepared track with content uri which follows trackID
+func (q *TrackQueue) Pop(trackID string) *Track {
+	for n, t := range q.tracks {
+		if t.PreparedFor == trackID && t.ContentURI != "" {
+			q.tracks = append(q.tracks[:n], q.tracks[n+1:]...)
+			return t
+		}
+	}
+
+	return nil
+}
+
+// Drop removes all tracks prepared for trackID; preparation is abandoned when track is destroyed
+func (q *TrackQueue) Drop(trackID string) {
+	res := q.tracks[:0]
+	for _, t := range q.tracks {
+		if t.PreparedFor != trackID {
+			res = append(res, t)
+		}
+	}
+
+	q.tracks = res
+}
+
+// Next returns the oldest prepared track with content uri without removing it
+func (q *TrackQueue) Next() *Track {
+	for _, t := range q.tracks {
+		if t.ContentURI != "" {
+			return t
+		}
+	}
+
+	return nil
+}
+
+func (q *TrackQueue) Len() int {
+	return len(q.tracks)
+}
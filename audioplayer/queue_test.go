@@ -0,0 +1,76 @@
+package audioplayer
+
+import (
+	"testing"
+)
+
+func TestTrackQueue(t *testing.T) {
+	tests := []struct {
+		name    string
+		actions func(q *TrackQueue)
+		pop     string
+		want    string
+		wantLen int
+	}{
+		{
+			name: "gapless, prepared track follows current",
+			actions: func(q *TrackQueue) {
+				q.Prepare("TK_MUSIC_PID_293_PKT_131072_QUE_5_8")
+				q.Fill("/data/mnt/internal/MUSIC/2.flac")
+			},
+			pop:     "TK_MUSIC_PID_293_PKT_131072_QUE_5_8",
+			want:    "/data/mnt/internal/MUSIC/2.flac",
+			wantLen: 0,
+		},
+		{
+			name: "manual change after preloading, prepared track dropped with destroyed one",
+			actions: func(q *TrackQueue) {
+				q.Prepare("TK_MUSIC_PID_307_PKT_131072_QUE_5_3")
+				q.Fill("/data/mnt/internal/MUSIC/02.mp3")
+				q.Drop("TK_MUSIC_PID_307_PKT_131072_QUE_5_3")
+			},
+			pop:     "TK_MUSIC_PID_307_PKT_131072_QUE_5_4",
+			want:    "",
+			wantLen: 0,
+		},
+		{
+			name: "preparation without content uri is dropped on loaded state",
+			actions: func(q *TrackQueue) {
+				q.Prepare("TK_MUSIC_PID_311_PKT_131072_QUE_5_24")
+				q.DropPending()
+			},
+			pop:     "TK_MUSIC_PID_311_PKT_131072_QUE_5_24",
+			want:    "",
+			wantLen: 0,
+		},
+		{
+			name: "track prepared for other track is kept",
+			actions: func(q *TrackQueue) {
+				q.Prepare("TK_MUSIC_PID_312_PKT_131072_QUE_5_4")
+				q.Fill("/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac")
+			},
+			pop:     "TK_MUSIC_PID_312_PKT_131072_QUE_5_5",
+			want:    "",
+			wantLen: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &TrackQueue{}
+			tt.actions(q)
+
+			got := ""
+			if tr := q.Pop(tt.pop); tr != nil {
+				got = tr.ContentURI
+			}
+
+			if got != tt.want {
+				t.Errorf("Pop() = %v, want %v", got, tt.want)
+			}
+
+			if q.Len() != tt.wantLen {
+				t.Errorf("Len() = %v, want %v", q.Len(), tt.wantLen)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package audioplayer
+
+import (
+	"errors"
+	"log/slog"
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"slices"
+	"strings"
+)
+
+// missing returns required content fields not filled by resolver, start time is set by player
+func missing(c *resolver.Content) []string {
+	return slices.DeleteFunc(c.Missing(), func(s string) bool { return s == "startedAt" })
+}
+
+type resolution struct {
+	play    int
+	uri     string
+	content *resolver.Content
+	err     error
+}
+
+// endedPlay is a play which ended before its content was resolved
+type endedPlay struct {
+	track     Track
+	startedAt int64
+	reason    playerevents.EndReason
+}
+
+// newPlay starts a new play of current track; content is resolved in background
+func (p *AudioPlayer) newPlay() {
+	p.play++
+	p.startTrace()
+	p.CurrentContent = &resolver.Content{}
+	p.CurrentTrack.Uptime = 0
+	p.CurrentTrack.Suspended = 0
+	p.CurrentTrack.Seeked = false
+	p.CurrentTrack.Excluded = ""
+	p.command = playerevents.EndReasonNone
+	p.minimumListenDuration = 0
+	p.requestResolve()
+}
+
+// requestResolve resolves current track content once per play; storage is not touched while it is unmounted, see mounted
+func (p *AudioPlayer) requestResolve() {
+	if p.CurrentTrack.ContentURI == "" || p.requested == p.play || p.State == StateStorageUnmounted {
+		return
+	}
+
+	p.requested = p.play
+	uri := p.CurrentTrack.ContentURI
+	play := p.play
+
+	slog.Debug("resolving", "contentURI", uri, "play", play)
+
+	if p.syncResolve {
+		c, err := p.Resolve(uri)
+		p.resolved <- resolution{play: play, uri: uri, content: c, err: err}
+		return
+	}
+
+	go func() {
+		c, err := p.Resolve(uri)
+		p.resolved <- resolution{play: play, uri: uri, content: c, err: err}
+	}()
+}
+
+// WithSyncResolve makes player resolve content right away instead of background,
+// resolved content is applied by ApplyResolved
+func (p *AudioPlayer) WithSyncResolve() *AudioPlayer {
+	p.syncResolve = true
+	return p
+}
+
+// ApplyResolved applies all resolutions which are ready
+func (p *AudioPlayer) ApplyResolved() error {
+	var errs []error
+	for {
+		select {
+		case r := <-p.resolved:
+			if err := p.ApplyResolution(r); err != nil {
+				errs = append(errs, err)
+			}
+		default:
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// ApplyResolution sets resolved content on the play it was requested for
+//
+// If that play has already ended, it is sent to scrobbler as skipped
+func (p *AudioPlayer) ApplyResolution(r resolution) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if r.play != p.play {
+		ended, ok := p.ended[r.play]
+		if !ok {
+			return r.err
+		}
+
+		delete(p.ended, r.play)
+
+		if r.err != nil {
+			p.decide(r.play, VerdictNotSent, "resolver error: %s", r.err.Error())
+			return r.err
+		}
+
+		c := *r.content
+		c.StartedAt = ended.startedAt
+		c.Attempted = true
+		c.Rating = false
+
+		if !c.Valid() {
+			p.decide(r.play, VerdictNotSent, "resolved after play ended, content is missing %s", strings.Join(c.Missing(), ", "))
+			return nil
+		}
+
+		if rule := p.excluded(ended.track.ContentURI, c); rule != "" {
+			p.decide(r.play, VerdictNotSent, "resolved after play ended, excluded by rule %s", rule)
+			return nil
+		}
+
+		p.emit(played(c, ended.track, ended.reason), ended.track.Uptime)
+		slog.Info("sent to scrobbler as skipped", "uri", ended.track.ContentURI, "for", ended.track.PlayingFor, "reason", ended.reason)
+		p.decide(r.play, VerdictSkipped, "resolved after play ended, sent as skipped")
+
+		return nil
+	}
+
+	if p.State == StateStorageUnmounted {
+		// resolved from missing files, content is resolved again once storage is mounted
+		p.requested = 0
+		slog.Debug("resolved while storage is unmounted, dropped", "uri", r.uri)
+		return nil
+	}
+
+	if r.err != nil {
+		p.CurrentContent.Attempted = true
+		p.note("resolver error: %s", r.err.Error())
+		return r.err
+	}
+
+	c := r.content
+	c.StartedAt = p.CurrentContent.StartedAt
+	c.Attempted = true
+	c.Rating = false
+
+	p.CurrentContent = c
+	p.minimumListenDuration = int(c.Duration) / p.divider
+	p.modes.resolved(*c)
+
+	p.note("resolved: artist %q, track %q, duration %d s", c.Artist, c.Track, c.Duration)
+	if len(c.Sources) > 0 {
+		p.note("sources: %s", c.Origin())
+	}
+	if missing := missing(c); len(missing) > 0 {
+		p.note("content is missing %s, play won't be sent", strings.Join(missing, ", "))
+	}
+
+	p.CurrentTrack.Excluded = p.excluded(p.CurrentTrack.ContentURI, *c)
+	if p.CurrentTrack.Excluded != "" {
+		p.note("excluded by rule %s, play won't be sent", p.CurrentTrack.Excluded)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package audioplayer
+
+import (
+	"github.com/google/go-cmp/cmp"
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"testing"
+)
+
+// SlowResolver resolves after release is closed
+type SlowResolver struct {
+	release chan struct{}
+}
+
+func (s *SlowResolver) Resolve(uri string) (*resolver.Content, error) {
+	<-s.release
+	return (&DumbResolver{}).Resolve(uri)
+}
+
+func TestAudioPlayer_ApplyResolution(t *testing.T) {
+	r := &SlowResolver{release: make(chan struct{})}
+	p := newTestPlayer(r)
+	emitter := p.PlayerEventEmitter()
+
+	p.SetContentURI("/data/mnt/internal/MUSIC/1.flac")
+	p.CreateTrack("TK_MUSIC_PID_312_PKT_131072_QUE_5_3")
+	tick(t, p, 3)
+
+	// skipped to next track before content has been resolved
+	p.DestroyTrack("TK_MUSIC_PID_312_PKT_131072_QUE_5_3")
+	p.SetContentURI("/data/mnt/internal/MUSIC/2.flac")
+
+	close(r.release)
+
+	for i := 0; i < 2; i++ {
+		if err := p.ApplyResolution(<-p.resolved); err != nil {
+			t.Fatalf("ApplyResolution() error = %v", err)
+		}
+	}
+
+	if len(emitter) != 1 {
+		t.Fatalf("events = %d, want 1", len(emitter))
+	}
+
+	e := (<-emitter).(playerevents.PlayerEventTrackListened)
+	want := resolver.Content{
+		Artist:      "artist",
+		Album:       "album",
+		Track:       "/data/mnt/internal/MUSIC/1.flac",
+		TrackNumber: "1",
+		Duration:    10,
+		StartedAt:   12345,
+		Attempted:   true,
+	}
+	if diff := cmp.Diff(want, e.Content); diff != "" {
+		t.Errorf("content mismatch (-want +got):\n%s", diff)
+	}
+
+	if p.CurrentContent.Track != "/data/mnt/internal/MUSIC/2.flac" {
+		t.Errorf("current content = %v, want %v", p.CurrentContent.Track, "/data/mnt/internal/MUSIC/2.flac")
+	}
+}
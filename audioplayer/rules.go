@@ -0,0 +1,25 @@
+package audioplayer
+
+import (
+	"scrobbler/resolver"
+	"scrobbler/rules"
+)
+
+// WithRules makes player keep content excluded by rules out of scrobbler
+func (p *AudioPlayer) WithRules(r *rules.Rules) *AudioPlayer {
+	p.rules = r
+	return p
+}
+
+// excluded returns description of the rule which excludes content, empty if content is scrobbled
+func (p *AudioPlayer) excluded(uri string, c resolver.Content) string {
+	if p.rules == nil {
+		return ""
+	}
+
+	if rule, ok := p.rules.Excluded(uri, c); ok {
+		return rule.String()
+	}
+
+	return ""
+}
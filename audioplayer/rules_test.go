@@ -0,0 +1,59 @@
+package audioplayer
+
+import (
+	"scrobbler/playerevents"
+	"scrobbler/rules"
+	"testing"
+)
+
+func TestAudioPlayer_WithRules(t *testing.T) {
+	tests := []struct {
+		name      string
+		uri       string
+		ticks     int
+		wantEvent bool
+	}{
+		{
+			name:      "music listened",
+			uri:       "/data/mnt/internal/MUSIC/1.flac",
+			ticks:     6,
+			wantEvent: true,
+		},
+		{
+			name:      "music skipped",
+			uri:       "/data/mnt/internal/MUSIC/1.flac",
+			ticks:     3,
+			wantEvent: true,
+		},
+		{
+			name:  "podcast listened",
+			uri:   "/data/mnt/internal/PODCASTS/1.mp3",
+			ticks: 6,
+		},
+		{
+			name:  "podcast skipped",
+			uri:   "/data/mnt/internal/PODCASTS/1.mp3",
+			ticks: 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlayer(&DumbResolver{}).WithRules(rules.New())
+			events := p.PlayerEventEmitter()
+
+			playFor(t, p, tt.uri, "TK_1", tt.ticks)
+
+			p.Command(playerevents.EndReasonNext)
+			p.DestroyTrack("TK_1")
+			p.SetContentURI("/data/mnt/internal/MUSIC/2.flac")
+
+			if got := len(events) > 0; got != tt.wantEvent {
+				t.Errorf("event sent = %v, want %v", got, tt.wantEvent)
+			}
+
+			if !tt.wantEvent && p.Explain()[0].Verdict != VerdictNotSent {
+				t.Errorf("Verdict = %v, want %v", p.Explain()[0].Verdict, VerdictNotSent)
+			}
+		})
+	}
+}
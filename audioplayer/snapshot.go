@@ -0,0 +1,39 @@
+package audioplayer
+
+import (
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"time"
+)
+
+// Snapshot is a copy of player state, safe to use from other goroutines
+type Snapshot struct {
+	State     int
+	URI       string
+	Elapsed   int // seconds
+	Content   resolver.Content
+	NextTrack string        // uri of prepared track, empty if there is none
+	Suspended time.Duration // time current play spent in suspend
+	Mode      playerevents.PlayMode
+}
+
+// Snapshot returns player state taken under lock
+func (p *AudioPlayer) Snapshot() Snapshot {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	s := Snapshot{
+		State:     p.State,
+		URI:       p.CurrentTrack.ContentURI,
+		Elapsed:   p.CurrentTrack.PlayingFor,
+		Content:   *p.CurrentContent,
+		Suspended: p.CurrentTrack.Suspended,
+		Mode:      p.modes.mode,
+	}
+
+	if t := p.Prepared.Next(); t != nil {
+		s.NextTrack = t.ContentURI
+	}
+
+	return s
+}
@@ -0,0 +1,58 @@
+package audioplayer
+
+import (
+	"scrobbler/parser"
+	"scrobbler/playerevents"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAudioPlayer_Snapshot(t *testing.T) {
+	p := New().WithResolver(&DumbResolver{}).WithClock(&staticClock{}).WithTickDuration(time.Millisecond).WithPlayerEventEmitter(make(chan playerevents.PlayerEvent, 10))
+
+	stop := make(chan struct{})
+	errCh := make(chan error, 100)
+	wg := &sync.WaitGroup{}
+
+	wg.Add(1)
+	go func() {
+		p.Consume(stop, errCh)
+		wg.Done()
+	}()
+
+	events := []parser.Event{
+		parser.EventContentURI{URI: "/data/mnt/internal/MUSIC/1.flac"},
+		parser.EventTrackCreated{TrackID: "TK_MUSIC_PID_293_PKT_131072_QUE_5_8"},
+		parser.EventPlayerStateChange{Before: StateByID[StatePause], After: StateByID[StateExecuting]},
+		parser.EventPreparing{},
+		parser.EventContentURI{URI: "/data/mnt/internal/MUSIC/2.flac"},
+	}
+
+	for _, e := range events {
+		*p.Consumer() <- e
+		_ = p.Snapshot()
+	}
+
+	time.Sleep(time.Millisecond * 20)
+
+	s := p.Snapshot()
+	stop <- struct{}{}
+	wg.Wait()
+
+	if s.URI != "/data/mnt/internal/MUSIC/1.flac" {
+		t.Errorf("URI = %v, want %v", s.URI, "/data/mnt/internal/MUSIC/1.flac")
+	}
+
+	if s.NextTrack != "/data/mnt/internal/MUSIC/2.flac" {
+		t.Errorf("NextTrack = %v, want %v", s.NextTrack, "/data/mnt/internal/MUSIC/2.flac")
+	}
+
+	if s.State != StateExecuting {
+		t.Errorf("State = %v, want %v", StateByID[s.State], StateByID[StateExecuting])
+	}
+
+	if s.Elapsed == 0 {
+		t.Errorf("Elapsed = 0, want > 0")
+	}
+}
@@ -0,0 +1,90 @@
+package audioplayer
+
+import (
+	"log/slog"
+	"scrobbler/device"
+	"scrobbler/playerevents"
+)
+
+// send passes event to sinks; events are queued while storage is unmounted, because sinks write to it
+//
+// Events of SD card tracks are logged to the card, so they wait for the card as well.
+func (p *AudioPlayer) send(e playerevents.PlayerEventTrackListened) {
+	if e.External && p.cardUnmounted {
+		slog.Debug("sd card is unmounted, queueing event", "track", e.Content.Track)
+		p.cardQueued = enqueue(p.cardQueued, e)
+		return
+	}
+
+	if p.State != StateStorageUnmounted {
+		p.emitter <- e
+		return
+	}
+
+	slog.Debug("storage is unmounted, queueing event", "track", e.Content.Track)
+	p.queued = enqueue(p.queued, e)
+}
+
+func enqueue(q []playerevents.PlayerEventTrackListened, e playerevents.PlayerEventTrackListened) []playerevents.PlayerEventTrackListened {
+	q = append(q, e)
+	if len(q) > HeldLimit {
+		slog.Warn("too many queued events, dropping oldest", "track", q[0].Content.Track)
+		q = q[1:]
+	}
+
+	return q
+}
+
+// mounted sends events queued while storage was unmounted, finishes deferred checkpoint removal
+// and resolves current content, which has not been resolved while storage was unmounted
+func (p *AudioPlayer) mounted() {
+	for _, e := range p.queued {
+		p.emitter <- e
+	}
+
+	p.queued = nil
+
+	if p.checkpointDeferred {
+		p.checkpointDeferred = false
+		if err := p.dropCheckpoint(); err != nil {
+			slog.Error("mounted", "error", err.Error())
+		}
+	}
+
+	p.requestResolve()
+}
+
+// cardUnmounting finalizes play of a track on SD card, player state is not affected;
+// events of card tracks are queued until the card is mounted again
+func (p *AudioPlayer) cardUnmounting(storage string) {
+	slog.Info("storage unmounting", "storage", storage)
+
+	p.lock.Lock()
+	p.cardUnmounted = true
+	p.flushDestroyed()
+	external := device.IsExternal(p.CurrentTrack.ContentURI)
+	if external {
+		p.finish(playerevents.EndReasonUnmount)
+	}
+	p.lock.Unlock()
+
+	if external {
+		p.Stop()
+	}
+}
+
+// cardMounted sends events queued while SD card was unmounted
+func (p *AudioPlayer) cardMounted(storage string) {
+	slog.Info("storage mounted", "storage", storage)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	queued := p.cardQueued
+	p.cardQueued = nil
+	p.cardUnmounted = false
+
+	for _, e := range queued {
+		p.send(e)
+	}
+}
@@ -0,0 +1,153 @@
+package audioplayer
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"scrobbler/parser"
+	"scrobbler/playerevents"
+	"testing"
+)
+
+func TestAudioPlayer_StorageUnmount(t *testing.T) {
+	every := CheckpointEvery
+	CheckpointEvery = 2
+	defer func() { CheckpointEvery = every }()
+
+	checkpoint := filepath.Join(t.TempDir(), "state")
+	p := newTestPlayer(&DumbResolver{}).WithCheckpoint(checkpoint)
+	emitter := p.PlayerEventEmitter()
+
+	playFor(t, p, "/data/mnt/internal/MUSIC/1.flac", "TK_1", 4)
+
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("checkpoint must be saved: %v", err)
+	}
+
+	if err := p.Handle(parser.EventStorageUnmounting{Storage: parser.StorageInternal}); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	if len(emitter) != 0 {
+		t.Fatalf("events = %d, want 0 while storage is unmounted", len(emitter))
+	}
+
+	if _, err := os.Stat(checkpoint); err != nil {
+		t.Fatalf("checkpoint must not be touched while storage is unmounted: %v", err)
+	}
+
+	if err := p.Handle(parser.EventStorageMounted{Storage: parser.StorageInternal}); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	if len(emitter) != 1 {
+		t.Fatalf("events = %d, want 1 after storage is mounted", len(emitter))
+	}
+
+	e := (<-emitter).(playerevents.PlayerEventTrackListened)
+	if e.Reason != playerevents.EndReasonUnmount || e.Content.Rating || e.ListenedFor != 4 {
+		t.Errorf("event = %+v, want skip after 4 s with reason %v", e, playerevents.EndReasonUnmount)
+	}
+
+	if _, err := os.Stat(checkpoint); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("checkpoint must be removed after storage is mounted, stat error = %v", err)
+	}
+}
+
+func TestAudioPlayer_StorageRemount(t *testing.T) {
+	uri := "/data/mnt/internal/MUSIC/1.flac"
+	p := newTestPlayer(&DumbResolver{}).WithSyncResolve()
+	emitter := p.PlayerEventEmitter()
+
+	playFor(t, p, uri, "TK_1", 2)
+
+	if err := p.Handle(parser.EventStorageUnmounting{Storage: parser.StorageInternal}); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	if len(p.resolved) != 0 {
+		t.Fatalf("resolutions = %d, want 0, storage must not be read while unmounted", len(p.resolved))
+	}
+
+	if err := p.Handle(parser.EventStorageMounted{Storage: parser.StorageInternal}); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	// same track continues after remount
+	p.SetContentURI(uri)
+	p.CreateTrack("TK_2")
+	if err := p.SetState(StateStorageMounted, StateExecuting); err != nil {
+		t.Fatalf("SetState() error = %v", err)
+	}
+
+	if err := p.ApplyResolved(); err != nil {
+		t.Fatalf("ApplyResolved() error = %v", err)
+	}
+
+	tick(t, p, 6)
+
+	if !p.CurrentContent.Valid() {
+		t.Fatalf("content = %+v, want content resolved after remount", p.CurrentContent)
+	}
+
+	if len(emitter) != 1 {
+		t.Errorf("events = %d, want 1 listen after remount", len(emitter))
+	}
+}
+
+func TestAudioPlayer_CardUnmount(t *testing.T) {
+	tests := []struct {
+		name      string
+		uri       string
+		wantEvent bool
+	}{
+		{
+			name:      "track on sd card",
+			uri:       "/contents_ext/MUSIC/1.flac",
+			wantEvent: true,
+		},
+		{
+			name: "track on internal storage",
+			uri:  "/data/mnt/internal/MUSIC/1.flac",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlayer(&DumbResolver{})
+			emitter := p.PlayerEventEmitter()
+
+			playFor(t, p, tt.uri, "TK_1", 4)
+
+			if err := p.Handle(parser.EventStorageUnmounting{Storage: parser.StorageExternal}); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+
+			if p.State != StateExecuting {
+				t.Errorf("State = %v, want %v", StateByID[p.State], StateByID[StateExecuting])
+			}
+
+			// scrobbler log of card tracks is on the card
+			if len(emitter) != 0 {
+				t.Fatalf("events = %d, want 0 while sd card is unmounted", len(emitter))
+			}
+
+			if err := p.Handle(parser.EventStorageMounted{Storage: parser.StorageExternal}); err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+
+			if got := len(emitter) > 0; got != tt.wantEvent {
+				t.Fatalf("event sent = %v, want %v", got, tt.wantEvent)
+			}
+
+			if !tt.wantEvent {
+				return
+			}
+
+			e := (<-emitter).(playerevents.PlayerEventTrackListened)
+			if e.Reason != playerevents.EndReasonUnmount || !e.External || e.ListenedFor != 4 {
+				t.Errorf("event = %+v, want skip of sd card track after 4 s with reason %v", e, playerevents.EndReasonUnmount)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package audioplayer
+
+import (
+	"log/slog"
+	"time"
+)
+
+// WithSuspendThreshold enables suspend detection: gap between ticks longer than d is a suspend period
+//
+// Walkman sleeps when paused or screen is off; ticker freezes and wall time jumps on wake.
+func (p *AudioPlayer) WithSuspendThreshold(d time.Duration) *AudioPlayer {
+	p.suspendThreshold = d
+	return p
+}
+
+// checkSuspend detects suspend periods between ticks
+//
+// Suspend period is not listening time: play counter is not touched, but player uptime is moved forward,
+// so rebased timestamps stay correct.
+func (p *AudioPlayer) checkSuspend(now time.Time, clockChanged bool) {
+	last := p.lastTick
+	p.lastTick = now
+
+	if last.IsZero() || clockChanged {
+		return
+	}
+
+	// wall time, monotonic clock stops during suspend
+	gap := now.Round(0).Sub(last.Round(0)) - p.tickDuration
+	if gap < p.suspendThreshold {
+		return
+	}
+
+	slog.Info("suspend detected", "for", gap.Round(time.Second), "state", StateByID[p.State], "uri", p.CurrentTrack.ContentURI)
+
+	p.uptime += gap
+	p.CurrentTrack.Suspended += gap
+	p.suspended += gap
+}
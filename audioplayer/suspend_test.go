@@ -0,0 +1,28 @@
+package audioplayer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAudioPlayer_Suspend(t *testing.T) {
+	clock := &settableClock{now: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)}
+	p := newTestPlayer(&DumbResolver{}).WithClock(clock).WithSuspendThreshold(time.Second * 30)
+
+	playFor(t, p, "/data/mnt/internal/MUSIC/1.flac", "TK_1", 3)
+
+	clock.now = clock.now.Add(time.Hour)
+	tick(t, p, 1)
+
+	if p.CurrentTrack.PlayingFor != 4 {
+		t.Errorf("PlayingFor = %v, want %v", p.CurrentTrack.PlayingFor, 4)
+	}
+
+	if p.CurrentTrack.Suspended != time.Hour {
+		t.Errorf("Suspended = %v, want %v", p.CurrentTrack.Suspended, time.Hour)
+	}
+
+	if p.uptime != time.Hour+time.Second*4 {
+		t.Errorf("uptime = %v, want %v", p.uptime, time.Hour+time.Second*4)
+	}
+}
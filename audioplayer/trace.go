@@ -0,0 +1,119 @@
+package audioplayer
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// TraceSize is the amount of finished play traces kept
+var TraceSize = 20
+
+const (
+	VerdictListened = "listened"
+	VerdictSkipped  = "skipped"
+	VerdictNotSent  = "not sent"
+)
+
+// Trace explains why play was or wasn't sent to scrobbler
+type Trace struct {
+	Play    int
+	URI     string
+	Notes   []string
+	Verdict string // empty while decision hasn't been made
+}
+
+func (t Trace) String() string {
+	verdict := t.Verdict
+	if verdict == "" {
+		verdict = "undecided"
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "play %d %s: %s\n", t.Play, t.URI, verdict)
+	for _, n := range t.Notes {
+		fmt.Fprintf(b, "  %s\n", n)
+	}
+
+	return b.String()
+}
+
+func (p *AudioPlayer) WithTraceSize(size int) *AudioPlayer {
+	p.traceSize = size
+	return p
+}
+
+// startTrace finishes trace of previous play and starts a new one for current play
+func (p *AudioPlayer) startTrace() {
+	if p.trace != nil && (p.trace.URI != "" || len(p.trace.Notes) > 0) {
+		p.traces = append(p.traces, p.trace)
+		if len(p.traces) > p.traceSize {
+			p.traces = p.traces[len(p.traces)-p.traceSize:]
+		}
+	}
+
+	p.trace = &Trace{Play: p.play, URI: p.CurrentTrack.ContentURI}
+}
+
+// note adds a line to the trace of current play
+func (p *AudioPlayer) note(format string, args ...any) {
+	p.noteOn(p.play, format, args...)
+}
+
+// noteOn adds a line to the trace of given play, play may have already ended
+func (p *AudioPlayer) noteOn(play int, format string, args ...any) *Trace {
+	t := p.traceOf(play)
+	if t == nil {
+		return nil
+	}
+
+	n := fmt.Sprintf(format, args...)
+	t.Notes = append(t.Notes, n)
+	slog.Debug("trace", "play", play, "uri", t.URI, "note", n)
+
+	return t
+}
+
+// decide sets verdict on the trace of given play
+func (p *AudioPlayer) decide(play int, verdict string, format string, args ...any) {
+	if t := p.noteOn(play, format, args...); t != nil {
+		t.Verdict = verdict
+	}
+}
+
+func (p *AudioPlayer) traceOf(play int) *Trace {
+	if p.trace != nil && p.trace.Play == play {
+		return p.trace
+	}
+
+	for i := len(p.traces) - 1; i >= 0; i-- {
+		if p.traces[i].Play == play {
+			return p.traces[i]
+		}
+	}
+
+	return nil
+}
+
+// Explain returns traces of recent plays, current play is the last one
+func (p *AudioPlayer) Explain() []Trace {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	res := make([]Trace, 0, len(p.traces)+1)
+	for _, t := range p.traces {
+		res = append(res, copyTrace(t))
+	}
+
+	if p.trace != nil && p.trace.URI != "" {
+		res = append(res, copyTrace(p.trace))
+	}
+
+	return res
+}
+
+func copyTrace(t *Trace) Trace {
+	res := *t
+	res.Notes = append([]string(nil), t.Notes...)
+	return res
+}
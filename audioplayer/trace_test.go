@@ -0,0 +1,86 @@
+package audioplayer
+
+import (
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"strings"
+	"testing"
+)
+
+// MissResolver finds nothing
+type MissResolver struct{}
+
+func (MissResolver) Resolve(string) (*resolver.Content, error) {
+	return &resolver.Content{}, nil
+}
+
+func TestAudioPlayer_Explain(t *testing.T) {
+	tests := []struct {
+		name        string
+		resolver    resolver.Resolver
+		ticks       int
+		wantVerdict string
+		wantNote    string
+	}{
+		{
+			name:        "listened",
+			resolver:    &DumbResolver{},
+			ticks:       6,
+			wantVerdict: VerdictListened,
+			wantNote:    "listened for 5 s, 5 s required, sent as listened",
+		},
+		{
+			name:        "skipped",
+			resolver:    &DumbResolver{},
+			ticks:       3,
+			wantVerdict: VerdictSkipped,
+			wantNote:    "ended (next) after 3 s, below 5 s threshold, sent as skipped",
+		},
+		{
+			name:        "too short",
+			resolver:    &DumbResolver{},
+			ticks:       2,
+			wantVerdict: VerdictNotSent,
+			wantNote:    "ended (next) after 2 s, plays shorter than 3 s are ignored",
+		},
+		{
+			name:        "resolver miss",
+			resolver:    MissResolver{},
+			ticks:       6,
+			wantVerdict: VerdictNotSent,
+			wantNote:    "content is missing artist, track, duration",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newTestPlayer(tt.resolver)
+			playFor(t, p, "/data/mnt/internal/MUSIC/1.flac", "TK_1", tt.ticks)
+
+			p.Command(playerevents.EndReasonNext)
+			p.DestroyTrack("TK_1")
+			p.SetContentURI("/data/mnt/internal/MUSIC/2.flac")
+
+			traces := p.Explain()
+			if len(traces) != 2 {
+				t.Fatalf("traces = %d, want 2", len(traces))
+			}
+
+			got := traces[0]
+			if got.URI != "/data/mnt/internal/MUSIC/1.flac" {
+				t.Errorf("URI = %v, want %v", got.URI, "/data/mnt/internal/MUSIC/1.flac")
+			}
+
+			if got.Verdict != tt.wantVerdict {
+				t.Errorf("Verdict = %v, want %v\n%s", got.Verdict, tt.wantVerdict, got)
+			}
+
+			if !strings.Contains(got.String(), tt.wantNote) {
+				t.Errorf("trace has no %q:\n%s", tt.wantNote, got)
+			}
+
+			if traces[1].Verdict != "" {
+				t.Errorf("current play Verdict = %v, want none", traces[1].Verdict)
+			}
+		})
+	}
+}
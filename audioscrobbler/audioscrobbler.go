@@ -68,6 +68,13 @@ var Header = "#AUDIOSCROBBLER/1.1\n" +
 
 var Filename = "/data/mnt/internal/.scrobbler.log"
 
+// ExternalFilename is the log for tracks on SD card, Filename is used if card cannot be written
+var ExternalFilename = "/contents_ext/.scrobbler.log"
+
+// InvalidClockFilename is the log for plays with unknown start time, device clock was not set;
+// timestamps there must be fixed before submitting
+var InvalidClockFilename = "/data/mnt/internal/.scrobbler-invalid-clock.log"
+
 type Log interface {
 	New(client string, device string) error
 	Add(s string) error
@@ -92,6 +99,21 @@ func (l *FileLog) Listen(c chan playerevents.PlayerEvent, errCh chan error) {
 			switch e.(type) {
 			case playerevents.PlayerEventTrackListened:
 				event := e.(playerevents.PlayerEventTrackListened)
+				if event.ClockInvalid {
+					slog.Warn("device clock is invalid, adding to separate log", "track", event.Content.Track, "startedAt", event.Content.StartedAt)
+					errCh <- l.add(InvalidClockFilename, event.Content.String())
+					continue
+				}
+
+				if event.External {
+					err := l.add(ExternalFilename, event.Content.String())
+					if err == nil {
+						continue
+					}
+
+					slog.Warn("cannot write to sd card, using internal storage", "error", err.Error())
+				}
+
 				errCh <- l.Add(event.Content.String())
 			default:
 				errCh <- fmt.Errorf("unknown event: %s", reflect.TypeOf(e).String())
@@ -101,17 +123,22 @@ func (l *FileLog) Listen(c chan playerevents.PlayerEvent, errCh chan error) {
 }
 
 func (l *FileLog) Add(s string) error {
+	return l.add(Filename, s)
+}
+
+func (l *FileLog) add(filename string, s string) error {
 	var err error
 	var f *os.File
 
-	_, err = os.Stat(Filename)
+	_, err = os.Stat(filename)
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
-			slog.Debug("creating new log", "path", Filename)
+			slog.Debug("creating new log", "path", filename)
 
-			if f, err = os.Create(Filename); err != nil {
+			if f, err = os.Create(filename); err != nil {
 				return fmt.Errorf("cannot create new log file: %w", err)
 			}
+			defer f.Close()
 
 			h := Header + fmt.Sprintf("#CLIENT/%s on %s", l.client, l.device)
 			if _, err = f.WriteString(h + "\n"); err != nil {
@@ -123,7 +150,7 @@ func (l *FileLog) Add(s string) error {
 	}
 
 	if f == nil {
-		f, err = os.OpenFile(Filename, os.O_APPEND|os.O_RDWR, 0644)
+		f, err = os.OpenFile(filename, os.O_APPEND|os.O_RDWR, 0644)
 		if err != nil {
 			return fmt.Errorf("cannot open existing log file: %w", err)
 		}
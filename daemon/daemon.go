@@ -9,12 +9,15 @@ import (
 	"scrobbler/audioplayer"
 	"scrobbler/audioscrobbler"
 	"scrobbler/device"
+	"scrobbler/history"
 	"scrobbler/logreader"
 	"scrobbler/parser"
 	"scrobbler/playerevents"
 	"scrobbler/resolver"
+	"scrobbler/rules"
 	"scrobbler/server"
 	"strings"
+	"time"
 )
 
 var name = "scrobbler"
@@ -30,8 +33,27 @@ var Commit = func() string {
 	return ""
 }()
 
+// BuildTime is used to detect device clock which was never set; device cannot be older than the build
+var BuildTime = func() time.Time {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.time" {
+				if t, err := time.Parse(time.RFC3339, setting.Value); err == nil {
+					return t
+				}
+			}
+		}
+	}
+	return time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+}()
+
 var SystemLogFile = "/dev/log/main"
 var ListenPercent = 50
+var CheckpointFile = "/data/mnt/internal/.scrobbler.state"
+var SuspendThreshold = 30 * time.Second
+var RulesFile = "/data/mnt/internal/.scrobbler.rules.json"
+var PatternsFile = "/data/mnt/internal/.scrobbler.patterns"
+var OverridesFile = "/data/mnt/internal/.scrobbler.overrides.json"
 
 func SetupLog() {
 	level := slog.LevelInfo
@@ -57,6 +79,39 @@ func SetupLog() {
 	slog.SetDefault(nl)
 }
 
+// NewResolver wraps media database resolver with file tags and path templates, cache and user overrides
+func NewResolver(db resolver.Resolver) resolver.Resolver {
+	patterns, err := resolver.LoadPatterns(PatternsFile)
+	if err != nil {
+		slog.Error("cannot load patterns, using defaults", "error", err.Error())
+	}
+
+	pr, err := resolver.NewPatternResolver(patterns...)
+	if err != nil {
+		slog.Error("invalid pattern, using defaults", "error", err.Error())
+		pr, _ = resolver.NewPatternResolver(resolver.Patterns...)
+	}
+
+	composite := resolver.NewComposite(
+		resolver.Source{Name: "db", Resolver: db},
+		resolver.Source{Name: "tags", Resolver: resolver.FileResolver{}},
+		resolver.Source{Name: "path", Resolver: pr},
+	).WithPriority(resolver.FieldMusicBrainzTID, "tags")
+	cache := resolver.NewCache(composite, resolver.CacheSize, resolver.DBPath)
+
+	return resolver.NewOverrides(cache, OverridesFile)
+}
+
+// NewPlayer creates player with rules and listen percent, device specific state is left to the caller
+func NewPlayer(r resolver.Resolver) *audioplayer.AudioPlayer {
+	rr, err := rules.Load(RulesFile)
+	if err != nil {
+		slog.Error("cannot load rules, using defaults", "error", err.Error())
+	}
+
+	return audioplayer.New().WithRules(rr).WithResolver(r).WithListenPercent(ListenPercent)
+}
+
 func Start() error {
 	SetupLog()
 
@@ -104,9 +159,16 @@ func Start() error {
 	}
 
 	emitter := make(chan playerevents.PlayerEvent)
-	go scrobbler.Listen(emitter, errCh)
+	events := make(chan playerevents.PlayerEvent)
+	go playerevents.NewDedup(playerevents.DedupSize).Forward(emitter, events)
+	go scrobbler.Listen(events, errCh)
 
-	player := audioplayer.New().WithResolver(r).WithListenPercent(ListenPercent).WithPlayerEventEmitter(emitter)
+	h := history.New(history.Size)
+
+	player := NewPlayer(NewResolver(r)).WithHistory(h).WithPlayerEventEmitter(emitter).WithCheckpoint(CheckpointFile).WithMinValidTime(BuildTime).WithSuspendThreshold(SuspendThreshold)
+	if err = player.Restore(); err != nil {
+		slog.Error("cannot restore playback state", "error", err.Error())
+	}
 
 	pp := parser.LogParser{}
 	pp.Subscribe(player.Consumer())
@@ -121,7 +183,7 @@ func Start() error {
 	}()
 
 	s := server.New("/tmp/scrobbler.sock")
-	s.WithAudioPlayer(player)
+	s.WithAudioPlayer(player).WithHistory(h)
 	go s.Start()
 
 	stop := make(chan struct{})
@@ -0,0 +1,53 @@
+package device
+
+import (
+	"path"
+	"strings"
+)
+
+// ExternalPrefixes are content uri prefixes of files on SD card
+var ExternalPrefixes = []string{"/contents_ext/", "/data/mnt/external0/"}
+
+// IsExternal checks if file at uri is on SD card
+func IsExternal(uri string) bool {
+	for _, prefix := range ExternalPrefixes {
+		if strings.HasPrefix(uri, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// InternalPrefix is content uri prefix of files on internal storage
+var InternalPrefix = "/data/mnt/internal/"
+
+// Relative returns uri relative to storage root, e.g. MUSIC/Artist/1.flac; uri of unknown storage is returned as is
+func Relative(uri string) string {
+	if rest, ok := strings.CutPrefix(uri, InternalPrefix); ok {
+		return rest
+	}
+
+	for _, prefix := range ExternalPrefixes {
+		if rest, ok := strings.CutPrefix(uri, prefix); ok {
+			return rest
+		}
+	}
+
+	return uri
+}
+
+// MatchPath checks if uri is pattern itself or is under pattern directory, or matches pattern as a glob;
+// empty pattern matches everything. Prefix ends at a path boundary: MUSIC/Sleep doesn't match MUSIC/Sleepwalker.
+func MatchPath(pattern string, uri string) bool {
+	if pattern == "" {
+		return true
+	}
+
+	if rest, ok := strings.CutPrefix(uri, pattern); ok && (rest == "" || rest[0] == '/' || strings.HasSuffix(pattern, "/")) {
+		return true
+	}
+
+	ok, _ := path.Match(pattern, uri)
+	return ok
+}
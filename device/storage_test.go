@@ -0,0 +1,27 @@
+package device
+
+import "testing"
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		uri     string
+		want    bool
+	}{
+		{pattern: "", uri: "/data/mnt/internal/MUSIC/1.flac", want: true},
+		{pattern: "/data/mnt/internal/MUSIC/Sleep", uri: "/data/mnt/internal/MUSIC/Sleep/1.flac", want: true},
+		{pattern: "/data/mnt/internal/MUSIC/Sleep/", uri: "/data/mnt/internal/MUSIC/Sleep/1.flac", want: true},
+		{pattern: "/data/mnt/internal/MUSIC/Sleep", uri: "/data/mnt/internal/MUSIC/Sleepwalker/1.flac", want: false},
+		{pattern: "/data/mnt/internal/MUSIC/1.flac", uri: "/data/mnt/internal/MUSIC/1.flac", want: true},
+		{pattern: "/data/mnt/internal/MUSIC/1.fla", uri: "/data/mnt/internal/MUSIC/1.flac", want: false},
+		{pattern: "/data/mnt/internal/MUSIC/*.flac", uri: "/data/mnt/internal/MUSIC/1.flac", want: true},
+		{pattern: "/data/mnt/internal/MUSIC/*.flac", uri: "/data/mnt/internal/MUSIC/1.mp3", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.uri, func(t *testing.T) {
+			if got := MatchPath(tt.pattern, tt.uri); got != tt.want {
+				t.Errorf("MatchPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package history
+
+import (
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"sync"
+	"time"
+)
+
+// Size is the amount of plays kept in memory
+var Size = 200
+
+// SessionGap is the pause between plays which starts new listening session
+var SessionGap = 30 * time.Minute
+
+type Outcome string
+
+const (
+	OutcomeListened Outcome = "listened"
+	OutcomeSkipped  Outcome = "skipped"
+	// OutcomeDropped is a play too short or without content, not sent to scrobbler
+	OutcomeDropped Outcome = "dropped"
+)
+
+// Play is a finished play
+type Play struct {
+	URI         string
+	Content     resolver.Content
+	Start       time.Time
+	End         time.Time
+	ListenedFor int // seconds
+	Outcome     Outcome
+	Reason      playerevents.EndReason
+}
+
+// Session is a group of plays without long pauses between them
+type Session struct {
+	Start time.Time
+	End   time.Time
+	Plays []Play
+}
+
+// History is a bounded list of recent plays, safe for concurrent use
+type History struct {
+	lock  sync.Mutex
+	plays []Play
+	next  int
+	gap   time.Duration
+}
+
+func New(size int) *History {
+	return &History{plays: make([]Play, 0, size), gap: SessionGap}
+}
+
+func (h *History) WithSessionGap(d time.Duration) *History {
+	h.gap = d
+	return h
+}
+
+// Add records finished play, oldest play is forgotten when history is full
+func (h *History) Add(p Play) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.plays) < cap(h.plays) {
+		h.plays = append(h.plays, p)
+		return
+	}
+
+	if len(h.plays) == 0 {
+		return
+	}
+
+	h.plays[h.next] = p
+	h.next = (h.next + 1) % len(h.plays)
+}
+
+// Plays returns copy of history, oldest play first
+func (h *History) Plays() []Play {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	res := make([]Play, 0, len(h.plays))
+	res = append(res, h.plays[h.next:]...)
+	res = append(res, h.plays[:h.next]...)
+
+	return res
+}
+
+// Sessions groups history into listening sessions, oldest first
+func (h *History) Sessions() []Session {
+	var res []Session
+
+	for _, p := range h.Plays() {
+		if len(res) > 0 && p.Start.Sub(res[len(res)-1].End) <= h.gap {
+			s := &res[len(res)-1]
+			s.Plays = append(s.Plays, p)
+			if p.End.After(s.End) {
+				s.End = p.End
+			}
+			continue
+		}
+
+		res = append(res, Session{Start: p.Start, End: p.End, Plays: []Play{p}})
+	}
+
+	return res
+}
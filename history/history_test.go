@@ -0,0 +1,89 @@
+package history
+
+import (
+	"testing"
+	"time"
+)
+
+func play(uri string, start int64, listened int) Play {
+	return Play{
+		URI:         uri,
+		Start:       time.Unix(start, 0),
+		End:         time.Unix(start+int64(listened), 0),
+		ListenedFor: listened,
+		Outcome:     OutcomeListened,
+	}
+}
+
+func TestHistory_Plays(t *testing.T) {
+	tests := []struct {
+		name  string
+		size  int
+		plays []Play
+		want  []string
+	}{
+		{
+			name:  "not full",
+			size:  3,
+			plays: []Play{play("1.flac", 0, 10), play("2.flac", 10, 10)},
+			want:  []string{"1.flac", "2.flac"},
+		},
+		{
+			name:  "oldest forgotten",
+			size:  2,
+			plays: []Play{play("1.flac", 0, 10), play("2.flac", 10, 10), play("3.flac", 20, 10), play("4.flac", 30, 10)},
+			want:  []string{"3.flac", "4.flac"},
+		},
+		{
+			name:  "zero size",
+			size:  0,
+			plays: []Play{play("1.flac", 0, 10)},
+			want:  []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := New(tt.size)
+			for _, p := range tt.plays {
+				h.Add(p)
+			}
+
+			got := h.Plays()
+			if len(got) != len(tt.want) {
+				t.Fatalf("Plays() len = %d, want %d", len(got), len(tt.want))
+			}
+
+			for i := range got {
+				if got[i].URI != tt.want[i] {
+					t.Errorf("Plays()[%d] = %v, want %v", i, got[i].URI, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestHistory_Sessions(t *testing.T) {
+	h := New(10).WithSessionGap(time.Minute)
+	h.Add(play("1.flac", 0, 100))
+	h.Add(play("2.flac", 130, 100))
+	// long pause
+	h.Add(play("3.flac", 1000, 100))
+	h.Add(play("4.flac", 1100, 100))
+
+	got := h.Sessions()
+	if len(got) != 2 {
+		t.Fatalf("Sessions() len = %d, want 2", len(got))
+	}
+
+	if len(got[0].Plays) != 2 || len(got[1].Plays) != 2 {
+		t.Errorf("Sessions() plays = %d, %d, want 2, 2", len(got[0].Plays), len(got[1].Plays))
+	}
+
+	if !got[0].Start.Equal(time.Unix(0, 0)) || !got[0].End.Equal(time.Unix(230, 0)) {
+		t.Errorf("Sessions()[0] = %v - %v, want %v - %v", got[0].Start, got[0].End, time.Unix(0, 0), time.Unix(230, 0))
+	}
+
+	if !got[1].Start.Equal(time.Unix(1000, 0)) || !got[1].End.Equal(time.Unix(1200, 0)) {
+		t.Errorf("Sessions()[1] = %v - %v, want %v - %v", got[1].Start, got[1].End, time.Unix(1000, 0), time.Unix(1200, 0))
+	}
+}
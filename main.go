@@ -2,12 +2,23 @@ package main
 
 import (
 	"log/slog"
+	"os"
 	"scrobbler/daemon"
+	"scrobbler/replay"
 )
 
 func main() {
 	var err error
 
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		if err = replay.Command(os.Args[2:]); err != nil {
+			slog.Error("replay", "error", err.Error())
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	err = daemon.Start()
 	if err != nil {
 		slog.Error("daemon", "error", err.Error())
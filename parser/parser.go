@@ -21,23 +21,55 @@ var PreparedTrackMarker = "] Preparing next track."
 
 var EndOfStreamMarker = "] EOS received. nFilledLen ="
 
-var StorageUnmountingMarker = "storage[Internal], status[Unmounting]"
-var StorageMountedMarker = "storage[Internal], status[Mounted]"
+// StorageStatusMarker is followed by storage name and status, e.g. "storage[External0], status[Mounted]"
+var StorageStatusMarker = "StorageStatus: storage["
+
+const (
+	StorageInternal = "Internal"
+	StorageExternal = "External0" // SD card
+)
+
 var TrackDestroyedMarker = "] has been destroyed"
 var TrackCreatedMarker = "] has been created"
 var SoundServiceTrackSubstring = "] Track["
 var SleepForTestsMarker = "SLEEP FOR "
 
+// PlayerService calls, logged as "|PlayController_<Method>] Enter"
+var NextTrackMarker = "|PlayController_NextTrack] Enter"
+var SeekMarker = "|PlayController_SeekTime] Enter"
+var TrackSequenceMarker = "|PlayController_SetTrackSequence] Enter"
+
+// TimestampLayout is the timestamp at the start of every log line; there is no year in it
+var TimestampLayout = "01-02 15:04:05.000"
+
+// Timestamp returns time of log line in given year; device writes its local time, loc is the device time zone
+func Timestamp(s string, year int, loc *time.Location) (time.Time, bool) {
+	if len(s) < len(TimestampLayout) {
+		return time.Time{}, false
+	}
+
+	t, err := time.ParseInLocation(TimestampLayout, s[:len(TimestampLayout)], loc)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t.AddDate(year, 0, 0), true
+}
+
+// BeepURISubstring marks content uri of a beep, beeps are played as regular tracks
+var BeepURISubstring = "WM_BEEP"
+
 var Markers = map[string]func(string) string{
-	ContentURIMarker:        GetContentPath,
-	PlayerStateMarker:       GetPlayerState,
-	PreparedTrackMarker:     PreparedTrack,
-	EndOfStreamMarker:       EndOfStream,
-	StorageUnmountingMarker: StorageUnmounting,
-	StorageMountedMarker:    StorageMounted,
-	TrackDestroyedMarker:    TrackDestroyed,
-	TrackCreatedMarker:      TrackCreated,
-	SleepForTestsMarker:     SleepForTests,
+	ContentURIMarker:     GetContentPath,
+	PlayerStateMarker:    GetPlayerState,
+	PreparedTrackMarker:  PreparedTrack,
+	EndOfStreamMarker:    EndOfStream,
+	StorageStatusMarker:  StorageStatus,
+	TrackDestroyedMarker: TrackDestroyed,
+	TrackCreatedMarker:   TrackCreated,
+	NextTrackMarker:      PlayerServiceCall,
+	SeekMarker:           PlayerServiceCall,
+	TrackSequenceMarker:  PlayerServiceCall,
 }
 
 func SleepForTests(s string) string {
@@ -48,6 +80,16 @@ func SleepForTests(s string) string {
 
 }
 
+func PlayerServiceCall(s string) string {
+	start := strings.Index(s, "|PlayController_")
+	end := strings.Index(s, "] Enter")
+	if start < 0 || end < start {
+		return ""
+	}
+
+	return s[start+len("|PlayController_") : end]
+}
+
 func EndOfStream(s string) string {
 	if strings.Contains(s, EndOfStreamMarker) {
 		return "1"
@@ -84,20 +126,14 @@ func GetContentPath(s string) string {
 	return s[end:]
 }
 
-func StorageUnmounting(s string) string {
-	if !strings.Contains(s, StorageUnmountingMarker) {
+// StorageStatus returns "storage], status[status"
+func StorageStatus(s string) string {
+	start := strings.Index(s, StorageStatusMarker)
+	if start < 0 {
 		return ""
 	}
 
-	return s
-}
-
-func StorageMounted(s string) string {
-	if !strings.Contains(s, StorageMountedMarker) {
-		return ""
-	}
-
-	return s
+	return strings.TrimSuffix(strings.TrimSpace(s[start+len(StorageStatusMarker):]), "]")
 }
 
 func TrackDestroyed(s string) string {
@@ -138,6 +174,13 @@ type EventContentURI struct {
 
 func (e EventContentURI) String() {}
 
+// EventBeep is a content uri of a beep
+type EventBeep struct {
+	URI string
+}
+
+func (e EventBeep) String() {}
+
 type EventEndOfStream struct{}
 
 func (e EventEndOfStream) String() {}
@@ -149,11 +192,15 @@ type EventPlayerStateChange struct {
 
 func (e EventPlayerStateChange) String() {}
 
-type EventStorageMounted struct{}
+type EventStorageMounted struct {
+	Storage string
+}
 
 func (e EventStorageMounted) String() {}
 
-type EventStorageUnmounting struct{}
+type EventStorageUnmounting struct {
+	Storage string
+}
 
 func (e EventStorageUnmounting) String() {}
 
@@ -169,6 +216,19 @@ type EventTrackCreated struct {
 
 func (EventTrackCreated) String() {}
 
+type EventNextTrack struct{}
+
+func (EventNextTrack) String() {}
+
+type EventSeek struct{}
+
+func (EventSeek) String() {}
+
+// EventTrackSequence happens when player gets new list of tracks to play
+type EventTrackSequence struct{}
+
+func (EventTrackSequence) String() {}
+
 type LogParser struct {
 	subs []chan Event
 }
@@ -178,6 +238,35 @@ func (l *LogParser) Subscribe(e *chan Event) {
 }
 
 func (l *LogParser) Parse(s string) error {
+	if v := SleepForTests(s); v != "" {
+		d, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			slog.Error("cannot parse")
+		}
+
+		time.Sleep(time.Millisecond * time.Duration(d))
+		return nil
+	}
+
+	event, err := l.Event(s)
+	if err != nil {
+		return err
+	}
+
+	if event == nil {
+		return nil
+	}
+
+	for n, sub := range l.subs {
+		slog.Debug("parser sending", "event", reflect.TypeOf(event).String(), "subscriber", n, "data", fmt.Sprintf("%+v", event))
+		sub <- event
+	}
+
+	return nil
+}
+
+// Event parses log line, nil event is returned for lines without markers
+func (l *LogParser) Event(s string) (Event, error) {
 	value := ""
 	mark := ""
 	for marker, v := range Markers {
@@ -193,46 +282,49 @@ func (l *LogParser) Parse(s string) error {
 	case PreparedTrackMarker:
 		event = EventPreparing{}
 	case ContentURIMarker:
+		if strings.Contains(value, BeepURISubstring) {
+			event = EventBeep{URI: value}
+			break
+		}
+
 		event = EventContentURI{URI: value}
 	case EndOfStreamMarker:
 		event = EventEndOfStream{}
 	case PlayerStateMarker:
 		states := strings.Split(value, "->")
 		if len(states) != 2 {
-			return fmt.Errorf("cannot split player state in 2 by ->: %s; %s", value, s)
+			return nil, fmt.Errorf("cannot split player state in 2 by ->: %s; %s", value, s)
 		}
 
 		event = EventPlayerStateChange{
 			Before: states[0][1 : len(states[0])-1],
 			After:  states[1][1 : len(states[1])-1],
 		}
-	case StorageMountedMarker:
-		event = EventStorageMounted{}
-		slog.Debug("storage mounted")
-	case StorageUnmountingMarker:
-		event = EventStorageUnmounting{}
-		slog.Debug("storage unmounting")
+	case StorageStatusMarker:
+		storage, status, ok := strings.Cut(value, "], status[")
+		if !ok {
+			return nil, fmt.Errorf("cannot parse storage status: %s", s)
+		}
+
+		switch status {
+		case "Mounted":
+			event = EventStorageMounted{Storage: storage}
+			slog.Debug("storage mounted", "storage", storage)
+		case "Unmounting":
+			event = EventStorageUnmounting{Storage: storage}
+			slog.Debug("storage unmounting", "storage", storage)
+		}
 	case TrackDestroyedMarker:
 		event = EventTrackDestroyed{TrackID: value}
 	case TrackCreatedMarker:
 		event = EventTrackCreated{TrackID: value}
-	case SleepForTestsMarker:
-		d, err := strconv.ParseInt(value, 10, 32)
-		if err != nil {
-			slog.Error("cannot parse")
-		}
-
-		time.Sleep(time.Millisecond * time.Duration(d))
+	case NextTrackMarker:
+		event = EventNextTrack{}
+	case SeekMarker:
+		event = EventSeek{}
+	case TrackSequenceMarker:
+		event = EventTrackSequence{}
 	}
 
-	if event == nil {
-		return nil
-	}
-
-	for n, sub := range l.subs {
-		slog.Debug("parser sending", "event", reflect.TypeOf(event).String(), "subscriber", n, "data", fmt.Sprintf("%+v", event))
-		sub <- event
-	}
-
-	return nil
+	return event, nil
 }
@@ -206,6 +206,31 @@ func TestLogParser_Parse(t *testing.T) {
 			want:    []Event{EventContentURI{"/content"}},
 			wantErr: false,
 		},
+		{
+			name: "player service calls",
+			args: args{
+				expectedEvents: 3,
+				filename:       "",
+				lines: []string{
+					"I/hagodaemon(  279): [I|  292|b6c50000|PLYRSRVC|PlayerServiceService.cc:486|PlayController_SetTrackSequence] Enter",
+					"I/hagodaemon(  294): [I|  326|b6cd1000|PLYRSRVC|PlayerServiceService.cc:392|PlayController_NextTrack] Enter",
+					"I/hagodaemon(  294): [I|  326|b6cd1000|PLYRSRVC|PlayerServiceService.cc:392|PlayController_NextTrack] Exit",
+					"I/hagodaemon(  292): [I|  324|b6ceb000|PLYRSRVC|PlayerServiceService.cc:466|PlayController_SeekTime] Enter",
+				},
+			},
+			want:    []Event{EventTrackSequence{}, EventNextTrack{}, EventSeek{}},
+			wantErr: false,
+		},
+		{
+			name: "beep content uri event",
+			args: args{
+				expectedEvents: 1,
+				filename:       "",
+				lines:          []string{ContentURIMarker + "/system/usr/share/sounds/WM_BEEP_VOLUME.wav"},
+			},
+			want:    []Event{EventBeep{"/system/usr/share/sounds/WM_BEEP_VOLUME.wav"}},
+			wantErr: false,
+		},
 
 		{
 			name: "end of stream event",
@@ -235,9 +260,9 @@ func TestLogParser_Parse(t *testing.T) {
 			args: args{
 				expectedEvents: 1,
 				filename:       "",
-				lines:          []string{StorageMountedMarker},
+				lines:          []string{"[SMGR|StorageMgrImpl.cc:1581] " + StorageStatusMarker + "Internal], status[Mounted]"},
 			},
-			want:    []Event{EventStorageMounted{}},
+			want:    []Event{EventStorageMounted{Storage: StorageInternal}},
 			wantErr: false,
 		},
 		{
@@ -245,9 +270,22 @@ func TestLogParser_Parse(t *testing.T) {
 			args: args{
 				expectedEvents: 1,
 				filename:       "",
-				lines:          []string{StorageUnmountingMarker},
+				lines:          []string{"[SMGR|StorageMgrImpl.cc:1581] " + StorageStatusMarker + "Internal], status[Unmounting]"},
+			},
+			want:    []Event{EventStorageUnmounting{Storage: StorageInternal}},
+			wantErr: false,
+		},
+		{
+			name: "sd card mounted event",
+			args: args{
+				expectedEvents: 1,
+				filename:       "",
+				lines: []string{
+					"[SMGR|StorageMgrImpl.cc:1581] " + StorageStatusMarker + "External0], status[NoMedia]",
+					"[SMGR|StorageMgrImpl.cc:1581] " + StorageStatusMarker + "External0], status[Mounted]",
+				},
 			},
-			want:    []Event{EventStorageUnmounting{}},
+			want:    []Event{EventStorageMounted{Storage: StorageExternal}},
 			wantErr: false,
 		},
 		{
@@ -273,12 +311,13 @@ func TestLogParser_Parse(t *testing.T) {
 		{
 			name: "many events",
 			args: args{
-				expectedEvents: 107,
+				expectedEvents: 109,
 				filename:       "test/many_events.log",
 				lines:          nil,
 			},
 			want: []Event{
-				EventStorageMounted{},
+				EventStorageMounted{Storage: StorageInternal},
+				EventTrackSequence{},
 				EventContentURI{URI: "/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf"},
 				EventPlayerStateChange{Before: "OMX_StateLoaded", After: "OMX_StateIdle"},
 				EventPlayerStateChange{Before: "OMX_StateLoaded", After: "OMX_StateIdle"},
@@ -379,6 +418,7 @@ func TestLogParser_Parse(t *testing.T) {
 				EventPlayerStateChange{Before: "OMX_StateIdle", After: "OMX_StateLoaded"},
 				EventPlayerStateChange{Before: "OMX_StateIdle", After: "OMX_StateLoaded"},
 				EventTrackDestroyed{TrackID: "TK_MUSIC_PID_312_PKT_131072_QUE_5_6"},
+				EventTrackSequence{},
 				EventContentURI{URI: "/data/mnt/internal/MUSIC/Don't Drift Too Far.dsf"},
 				EventPlayerStateChange{Before: "OMX_StateLoaded", After: "OMX_StateIdle"},
 				EventPlayerStateChange{Before: "OMX_StateLoaded", After: "OMX_StateIdle"},
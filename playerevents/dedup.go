@@ -0,0 +1,50 @@
+package playerevents
+
+import "log/slog"
+
+// DedupSize is the amount of recent plays remembered by Dedup
+var DedupSize = 16
+
+// Dedup sits between player and sinks and lets through only one event per play
+type Dedup struct {
+	seen []PlayID
+	next int
+}
+
+func NewDedup(size int) *Dedup {
+	return &Dedup{seen: make([]PlayID, 0, size)}
+}
+
+// Seen reports whether event for the same play has already been let through, event is remembered otherwise
+func (d *Dedup) Seen(e PlayerEventTrackListened) bool {
+	id := e.PlayID()
+	for _, s := range d.seen {
+		if s == id {
+			return true
+		}
+	}
+
+	if len(d.seen) < cap(d.seen) {
+		d.seen = append(d.seen, id)
+		return false
+	}
+
+	if len(d.seen) > 0 {
+		d.seen[d.next] = id
+		d.next = (d.next + 1) % len(d.seen)
+	}
+
+	return false
+}
+
+// Forward passes events from in to out, dropping duplicates
+func (d *Dedup) Forward(in chan PlayerEvent, out chan PlayerEvent) {
+	for e := range in {
+		if ee, ok := e.(PlayerEventTrackListened); ok && d.Seen(ee) {
+			slog.Warn("duplicate event dropped", "track", ee.Content.Track, "startedAt", ee.Content.StartedAt, "trackID", ee.TrackID)
+			continue
+		}
+
+		out <- e
+	}
+}
@@ -0,0 +1,77 @@
+package playerevents
+
+import (
+	"scrobbler/resolver"
+	"testing"
+)
+
+func TestDedup_Seen(t *testing.T) {
+	listened := func(track string, startedAt int64, trackID string) PlayerEventTrackListened {
+		return PlayerEventTrackListened{
+			Content: resolver.Content{Artist: "artist", Album: "album", Track: track, StartedAt: startedAt},
+			TrackID: trackID,
+		}
+	}
+
+	tests := []struct {
+		name   string
+		size   int
+		events []PlayerEventTrackListened
+		want   []bool
+	}{
+		{
+			name: "same play twice",
+			size: 4,
+			events: []PlayerEventTrackListened{
+				listened("1.flac", 12345, "TK_1"),
+				listened("1.flac", 12345, "TK_1"),
+			},
+			want: []bool{false, true},
+		},
+		{
+			name: "skip after listen of the same play",
+			size: 4,
+			events: []PlayerEventTrackListened{
+				listened("1.flac", 12345, "TK_1"),
+				func() PlayerEventTrackListened {
+					e := listened("1.flac", 12345, "TK_1")
+					e.Reason = EndReasonNext
+					return e
+				}(),
+			},
+			want: []bool{false, true},
+		},
+		{
+			name: "loop, same content played again",
+			size: 4,
+			events: []PlayerEventTrackListened{
+				listened("1.flac", 12345, "TK_1"),
+				listened("1.flac", 12355, "TK_1"),
+				listened("1.flac", 12365, "TK_2"),
+			},
+			want: []bool{false, false, false},
+		},
+		{
+			name: "forgotten after size plays",
+			size: 2,
+			events: []PlayerEventTrackListened{
+				listened("1.flac", 1, "TK_1"),
+				listened("2.flac", 2, "TK_2"),
+				listened("3.flac", 3, "TK_3"),
+				listened("2.flac", 2, "TK_2"),
+				listened("1.flac", 1, "TK_1"),
+			},
+			want: []bool{false, false, false, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDedup(tt.size)
+			for i, e := range tt.events {
+				if got := d.Seen(e); got != tt.want[i] {
+					t.Errorf("Seen() event %d = %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -6,8 +6,64 @@ type PlayerEvent interface {
 	String()
 }
 
+// EndReason tells why play ended
+type EndReason string
+
+const (
+	// EndReasonNone is used when listen threshold has been reached, play is still going on
+	EndReasonNone        EndReason = ""
+	EndReasonEndOfStream EndReason = "eos"
+	EndReasonNext        EndReason = "next"
+	EndReasonPrevious    EndReason = "previous" // detected from track order, see AudioPlayer.endReason
+	EndReasonUnmount     EndReason = "unmount"
+	EndReasonDestroyed   EndReason = "destroyed"
+)
+
+// PlayID identifies single play of content; same content played again gets new StartedAt
+type PlayID struct {
+	Artist    string
+	Album     string
+	Track     string
+	StartedAt int64
+	TrackID   string
+}
+
+// Repeat is player repeat mode
+type Repeat string
+
+const (
+	RepeatOff Repeat = ""
+	RepeatAll Repeat = "all"
+	RepeatOne Repeat = "one"
+)
+
+// PlayMode is play mode guessed from track order, player doesn't log it
+type PlayMode struct {
+	Shuffle bool
+	Repeat  Repeat
+}
+
 type PlayerEventTrackListened struct {
-	Content resolver.Content
+	Content      resolver.Content
+	TrackID      string    // SoundService track which played the content
+	ClockInvalid bool      // device clock was never set during the play, StartedAt is wrong
+	ListenedFor  int       // seconds
+	Fraction     float64   // ListenedFor / duration
+	Reason       EndReason // EndReasonNone for listened tracks
+	Seeked       bool      // position was changed during the play
+	Mode         PlayMode
+	External     bool // track is on SD card
 }
 
 func (pe PlayerEventTrackListened) String() {}
+
+// PlayID returns identity of the play event belongs to
+func (pe PlayerEventTrackListened) PlayID() PlayID {
+	return PlayID{
+		Artist:    pe.Content.Artist,
+		Album:     pe.Content.Album,
+		Track:     pe.Content.Track,
+		StartedAt: pe.Content.StartedAt,
+		TrackID:   pe.TrackID,
+	}
+}
@@ -0,0 +1,255 @@
+package replay
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"reflect"
+	"scrobbler/audioplayer"
+	"scrobbler/daemon"
+	"scrobbler/parser"
+	"scrobbler/playerevents"
+	"scrobbler/resolver"
+	"time"
+)
+
+// Clock is a virtual clock driven by log timestamps
+type Clock struct {
+	now time.Time
+}
+
+func (c *Clock) Now() time.Time { return c.now }
+
+// After fires right away, there is no waiting in replay
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	res := make(chan time.Time, 1)
+	res <- c.now.Add(d)
+	return res
+}
+
+// TraceSize is the amount of play traces kept for explanation
+var TraceSize = 10000
+
+// Replay runs captured log through parser and player, using log timestamps instead of real time
+type Replay struct {
+	clock    *Clock
+	player   *audioplayer.AudioPlayer
+	parser   *parser.LogParser
+	events   chan playerevents.PlayerEvent
+	dedup    *playerevents.Dedup
+	out      io.Writer
+	year     int
+	location *time.Location // time zone of log timestamps
+	lastTick time.Time
+}
+
+// New creates replay with the same resolvers and rules as daemon on top of media database resolver r
+func New(r resolver.Resolver, year int, out io.Writer) *Replay {
+	res := &Replay{
+		clock:    &Clock{},
+		parser:   &parser.LogParser{},
+		events:   make(chan playerevents.PlayerEvent, audioplayer.HeldLimit),
+		dedup:    playerevents.NewDedup(playerevents.DedupSize),
+		out:      out,
+		year:     year,
+		location: time.Local,
+	}
+
+	res.player = daemon.NewPlayer(daemon.NewResolver(r)).WithClock(res.clock).
+		WithPlayerEventEmitter(res.events).WithSyncResolve().WithTraceSize(TraceSize)
+
+	return res
+}
+
+// WithLocation sets time zone of the device which wrote the capture, local time zone by default
+func (r *Replay) WithLocation(loc *time.Location) *Replay {
+	r.location = loc
+	return r
+}
+
+// Logger returns trace logger which stamps records with virtual time
+func (r *Replay) Logger(w io.Writer, level slog.Level) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				a.Value = slog.TimeValue(r.clock.now)
+			}
+			return a
+		},
+	}
+
+	return slog.New(slog.NewTextHandler(w, opts))
+}
+
+// Run replays capture line by line
+func (r *Replay) Run(capture io.Reader) error {
+	s := bufio.NewScanner(capture)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for s.Scan() {
+		line := s.Text()
+
+		if t, ok := parser.Timestamp(line, r.year, r.location); ok {
+			r.advance(t)
+		}
+
+		event, err := r.parser.Event(line)
+		if err != nil {
+			slog.Error("cannot parse", "error", err.Error())
+			continue
+		}
+
+		if event == nil {
+			continue
+		}
+
+		slog.Info("event", "type", reflect.TypeOf(event).Name(), "data", fmt.Sprintf("%+v", event))
+
+		if err = r.player.Handle(event); err != nil {
+			slog.Error("player", "error", err.Error())
+		}
+
+		r.settle()
+	}
+
+	if err := s.Err(); err != nil {
+		return fmt.Errorf("cannot read capture: %w", err)
+	}
+
+	return nil
+}
+
+// advance ticks player once per tick duration until virtual clock reaches t
+func (r *Replay) advance(t time.Time) {
+	if r.lastTick.IsZero() || t.Before(r.lastTick) {
+		// first line or log wrapped around new year
+		r.lastTick = t
+		r.clock.now = t
+		return
+	}
+
+	for !r.lastTick.Add(time.Second).After(t) {
+		r.lastTick = r.lastTick.Add(time.Second)
+		r.clock.now = r.lastTick
+		if err := r.player.Tick(); err != nil {
+			slog.Error("tick", "error", err.Error())
+		}
+
+		r.settle()
+	}
+
+	r.clock.now = t
+}
+
+// settle applies resolved content and writes out emitted events
+func (r *Replay) settle() {
+	if err := r.player.ApplyResolved(); err != nil {
+		slog.Error("resolve", "error", err.Error())
+	}
+
+	for {
+		select {
+		case e := <-r.events:
+			r.write(e)
+		default:
+			return
+		}
+	}
+}
+
+func (r *Replay) write(e playerevents.PlayerEvent) {
+	event, ok := e.(playerevents.PlayerEventTrackListened)
+	if !ok {
+		return
+	}
+
+	if r.dedup.Seen(event) {
+		slog.Warn("duplicate event dropped", "track", event.Content.Track, "startedAt", event.Content.StartedAt)
+		return
+	}
+
+	slog.Info("scrobble", "track", event.Content.Track, "rating", event.Content.Rating, "for", event.ListenedFor, "reason", event.Reason)
+	fmt.Fprintln(r.out, event.Content.String())
+}
+
+// Explain writes decision trace of every play
+func (r *Replay) Explain(w io.Writer) {
+	for _, t := range r.player.Explain() {
+		fmt.Fprint(w, t.String())
+	}
+}
+
+// Command is `scrobbler replay [flags] <capture>`
+func Command(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	db := fs.String("db", resolver.DBPath, "media database copied from device")
+	year := fs.Int("year", time.Now().Year(), "year of the capture, log has no year in timestamps")
+	tz := fs.String("tz", "", "time zone of the device, e.g. Europe/Berlin, log has local time in timestamps (default local time zone)")
+	percent := fs.Int("percent", daemon.ListenPercent, "listen percent")
+	rulesFile := fs.String("rules", daemon.RulesFile, "rules copied from device")
+	patternsFile := fs.String("patterns", daemon.PatternsFile, "path templates copied from device")
+	overridesFile := fs.String("overrides", daemon.OverridesFile, "overrides copied from device")
+	verbose := fs.Bool("v", false, "debug trace")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: scrobbler replay [flags] <capture>\n\n"+
+			"Replays captured system log, prints .scrobbler.log lines to stdout, trace and explanation of every play to stderr.\n\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("capture file is required")
+	}
+
+	location := time.Local
+	if *tz != "" {
+		var err error
+		if location, err = time.LoadLocation(*tz); err != nil {
+			return fmt.Errorf("cannot load time zone: %w", err)
+		}
+	}
+
+	if _, err := os.Stat(*db); err != nil {
+		return fmt.Errorf("cannot open media database, use -db: %w", err)
+	}
+
+	resolver.DBPath = *db
+	daemon.ListenPercent = *percent
+	daemon.RulesFile = *rulesFile
+	daemon.PatternsFile = *patternsFile
+	daemon.OverridesFile = *overridesFile
+	res, err := resolver.New()
+	if err != nil {
+		return fmt.Errorf("cannot create resolver: %w", err)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("cannot open capture: %w", err)
+	}
+	defer f.Close()
+
+	r := New(res, *year, os.Stdout).WithLocation(location)
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(r.Logger(os.Stderr, level))
+
+	if err = r.Run(f); err != nil {
+		return err
+	}
+
+	r.Explain(os.Stderr)
+
+	return nil
+}
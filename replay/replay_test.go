@@ -0,0 +1,100 @@
+package replay
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"scrobbler/resolver"
+	"strings"
+	"testing"
+	"time"
+)
+
+// durationResolver gives every track the same duration
+type durationResolver struct {
+	duration uint
+}
+
+func (d *durationResolver) Resolve(uri string) (*resolver.Content, error) {
+	return &resolver.Content{
+		Artist:      "artist",
+		Album:       "album",
+		Track:       uri,
+		TrackNumber: "1",
+		Duration:    d.duration,
+	}, nil
+}
+
+func TestReplay_Run(t *testing.T) {
+	f, err := os.Open("../audioplayer/test/regular_play_track_skipped.log")
+	if err != nil {
+		t.Fatalf("cannot open capture: %s", err.Error())
+	}
+	defer f.Close()
+
+	out := &bytes.Buffer{}
+	trace := &bytes.Buffer{}
+	r := New(&durationResolver{duration: 150}, 2018, out).WithLocation(time.UTC)
+
+	def := slog.Default()
+	slog.SetDefault(r.Logger(trace, slog.LevelInfo))
+	defer slog.SetDefault(def)
+
+	start := time.Now()
+	if err = r.Run(f); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if time.Since(start) > time.Second*5 {
+		t.Errorf("replay took %s, must not sleep", time.Since(start))
+	}
+
+	want := "artist\talbum\t/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac\t1\t150\tL\t1515378559\t\n" +
+		"artist\talbum\t/data/mnt/internal/MUSIC/03 - Bucovina [Haaksman & Haaksman Soca Bogle Mix] - Shantel.mp3\t1\t150\tS\t1515378660\t\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+
+	if !strings.Contains(trace.String(), "time=2018-01-08T02:31:05.202Z level=INFO msg=\"sent to scrobbler as skipped\"") {
+		t.Errorf("trace has no skip decision at virtual time:\n%s", trace.String())
+	}
+
+	explain := &bytes.Buffer{}
+	r.Explain(explain)
+	if !strings.Contains(explain.String(), "/data/mnt/internal/MUSIC/03 - Bucovina [Haaksman & Haaksman Soca Bogle Mix] - Shantel.mp3: skipped\n") {
+		t.Errorf("explanation has no skipped play:\n%s", explain.String())
+	}
+}
+
+func TestReplay_WithLocation(t *testing.T) {
+	tests := []struct {
+		name string
+		loc  *time.Location
+		want string // start of the first listen
+	}{
+		{name: "utc", loc: time.UTC, want: "1515378559"},
+		{name: "east of utc", loc: time.FixedZone("UTC+3", 3*60*60), want: "1515367759"},
+		{name: "west of utc", loc: time.FixedZone("UTC-5", -5*60*60), want: "1515396559"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := os.Open("../audioplayer/test/regular_play_track_skipped.log")
+			if err != nil {
+				t.Fatalf("cannot open capture: %s", err.Error())
+			}
+			defer f.Close()
+
+			out := &bytes.Buffer{}
+			r := New(&durationResolver{duration: 150}, 2018, out).WithLocation(tt.loc)
+			if err = r.Run(f); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			first, _, _ := strings.Cut(out.String(), "\n")
+			fields := strings.Split(first, "\t")
+			if len(fields) < 7 || fields[6] != tt.want {
+				t.Errorf("first listen = %q, want started at %s", first, tt.want)
+			}
+		})
+	}
+}
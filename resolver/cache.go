@@ -0,0 +1,103 @@
+package resolver
+
+import (
+	"container/list"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// CacheSize is the amount of resolved uris kept in cache
+var CacheSize = 256
+
+type cached struct {
+	uri     string
+	content Content
+}
+
+// Cache keeps recently resolved content in memory
+//
+// Cache is dropped when database file is modified, e.g. after MediaStore rescan.
+// Content missing required fields is not cached, MediaStore may still be writing it.
+type Cache struct {
+	resolver Resolver
+	size     int
+	dbPath   string
+	mtime    time.Time
+	items    map[string]*list.Element
+	order    *list.List // most recently used first
+	lock     sync.Mutex
+}
+
+func NewCache(r Resolver, size int, dbPath string) *Cache {
+	return &Cache{
+		resolver: r,
+		size:     size,
+		dbPath:   dbPath,
+		items:    map[string]*list.Element{},
+		order:    list.New(),
+	}
+}
+
+func (c *Cache) Resolve(uri string) (*Content, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.validate()
+
+	if e, ok := c.items[uri]; ok {
+		c.order.MoveToFront(e)
+		res := e.Value.(*cached).content.clone()
+		return &res, nil
+	}
+
+	content, err := c.resolver.Resolve(uri)
+	if err != nil || content == nil || !complete(content) {
+		return content, err
+	}
+
+	// content is returned to caller by value, player fills rating and start time
+	entry := &cached{uri: uri, content: content.clone()}
+	c.items[uri] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cached).uri)
+	}
+
+	return content, nil
+}
+
+// validate drops cache if database has been modified since last check
+func (c *Cache) validate() {
+	info, err := os.Stat(c.dbPath)
+	if err != nil {
+		slog.Debug("cannot stat db, cache dropped", "error", err.Error())
+		c.drop()
+		return
+	}
+
+	if info.ModTime().Equal(c.mtime) {
+		return
+	}
+
+	if !c.mtime.IsZero() {
+		slog.Debug("db modified, cache dropped", "entries", c.order.Len())
+	}
+
+	c.mtime = info.ModTime()
+	c.drop()
+}
+
+func (c *Cache) drop() {
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+}
+
+func (c *Cache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.order.Len()
+}
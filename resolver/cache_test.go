@@ -0,0 +1,82 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type countingResolver struct {
+	calls map[string]int
+}
+
+func (r *countingResolver) Resolve(uri string) (*Content, error) {
+	r.calls[uri]++
+	if uri == "missing" {
+		return &Content{}, nil
+	}
+
+	return &Content{Artist: "artist", Track: uri, Duration: 100}, nil
+}
+
+func TestCache_Resolve(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "MTPDB.dat")
+	if err := os.WriteFile(db, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := &countingResolver{calls: map[string]int{}}
+	c := NewCache(r, 2, db)
+
+	resolve := func(uri string) *Content {
+		t.Helper()
+		res, err := c.Resolve(uri)
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		return res
+	}
+
+	first := resolve("1")
+	first.Rating = true
+	first.StartedAt = 1
+
+	if got := resolve("1"); got.Rating || got.StartedAt != 0 || got.Track != "1" {
+		t.Errorf("cached content modified by caller: %+v", got)
+	}
+
+	if r.calls["1"] != 1 {
+		t.Errorf("calls = %d, want 1", r.calls["1"])
+	}
+
+	resolve("missing")
+	resolve("missing")
+	if r.calls["missing"] != 2 {
+		t.Errorf("content with missing fields cached, calls = %d, want 2", r.calls["missing"])
+	}
+
+	// 1 is the most recently used, 2 evicts nothing, 3 evicts 2
+	resolve("2")
+	resolve("1")
+	resolve("3")
+	if c.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", c.Len())
+	}
+
+	resolve("1")
+	resolve("2")
+	if r.calls["1"] != 1 || r.calls["2"] != 2 {
+		t.Errorf("calls = %v, want 1 cached and 2 evicted", r.calls)
+	}
+
+	mtime := time.Now().Add(time.Minute)
+	if err := os.Chtimes(db, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	resolve("1")
+	if r.calls["1"] != 2 {
+		t.Errorf("cache not dropped after db modification, calls = %d, want 2", r.calls["1"])
+	}
+}
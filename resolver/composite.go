@@ -0,0 +1,164 @@
+package resolver
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"scrobbler/tags"
+	"strings"
+)
+
+// Field is a part of Content merged by Composite resolver
+type Field string
+
+const (
+	FieldArtist         Field = "artist"
+	FieldAlbum          Field = "album"
+	FieldTrack          Field = "track"
+	FieldTrackNumber    Field = "trackNumber"
+	FieldDuration       Field = "duration"
+	FieldMusicBrainzTID Field = "mbid"
+	FieldAlbumArtist    Field = "albumArtist"
+	FieldDiscNumber     Field = "discNumber"
+	FieldGenre          Field = "genre"
+	FieldYear           Field = "year"
+	FieldComposer       Field = "composer"
+	FieldAudio          Field = "audio" // sample rate, bitrate, channels, bit depth and raw attributes
+)
+
+type field struct {
+	name Field
+	has  func(c *Content) bool
+	copy func(dst, src *Content)
+}
+
+// fields are merged in this order
+var fields = []field{
+	{FieldArtist, func(c *Content) bool { return c.Artist != "" }, func(dst, src *Content) { dst.Artist = src.Artist }},
+	{FieldAlbum, func(c *Content) bool { return c.Album != "" }, func(dst, src *Content) { dst.Album = src.Album }},
+	{FieldTrack, func(c *Content) bool { return c.Track != "" }, func(dst, src *Content) { dst.Track = src.Track }},
+	{FieldTrackNumber, func(c *Content) bool { return c.TrackNumber != "" }, func(dst, src *Content) { dst.TrackNumber = src.TrackNumber }},
+	{FieldDuration, func(c *Content) bool { return c.Duration > 0 }, func(dst, src *Content) { dst.Duration = src.Duration }},
+	{FieldMusicBrainzTID, func(c *Content) bool { return c.MusicBrainzTID != "" }, func(dst, src *Content) { dst.MusicBrainzTID = src.MusicBrainzTID }},
+	{FieldAlbumArtist, func(c *Content) bool { return c.AlbumArtist != "" }, func(dst, src *Content) { dst.AlbumArtist = src.AlbumArtist }},
+	{FieldDiscNumber, func(c *Content) bool { return c.DiscNumber != "" }, func(dst, src *Content) { dst.DiscNumber = src.DiscNumber }},
+	{FieldGenre, func(c *Content) bool { return c.Genre != "" }, func(dst, src *Content) { dst.Genre = src.Genre }},
+	{FieldYear, func(c *Content) bool { return c.Year > 0 }, func(dst, src *Content) { dst.Year = src.Year }},
+	{FieldComposer, func(c *Content) bool { return c.Composer != "" }, func(dst, src *Content) { dst.Composer = src.Composer }},
+	{FieldAudio, func(c *Content) bool { return c.SampleRate > 0 }, func(dst, src *Content) {
+		dst.SampleRate, dst.Bitrate, dst.Channels, dst.BitDepth = src.SampleRate, src.Bitrate, src.Channels, src.BitDepth
+		dst.Attributes = src.Attributes
+	}},
+}
+
+// Source is a named resolver used by Composite
+type Source struct {
+	Name     string
+	Resolver Resolver
+}
+
+type sourceResult struct {
+	content *Content
+	err     error
+}
+
+// Composite merges content from several resolvers field by field
+//
+// Each field is taken from the first source which has it, sources are asked in order they were added
+// unless field has its own priority. Source is asked only when one of the fields needs it.
+// Name of the source which supplied each field is recorded in Content.Sources.
+type Composite struct {
+	sources  []Source
+	priority map[Field][]string
+}
+
+func NewComposite(sources ...Source) *Composite {
+	return &Composite{sources: sources, priority: map[Field][]string{}}
+}
+
+// WithPriority sets order in which sources are asked for field, sources not listed are asked after them
+func (c *Composite) WithPriority(f Field, names ...string) *Composite {
+	c.priority[f] = names
+	return c
+}
+
+// order returns sources for field
+func (c *Composite) order(f Field) []Source {
+	names := c.priority[f]
+	res := make([]Source, 0, len(c.sources))
+	for _, name := range names {
+		for _, s := range c.sources {
+			if s.Name == name {
+				res = append(res, s)
+			}
+		}
+	}
+
+	for _, s := range c.sources {
+		listed := false
+		for _, name := range names {
+			listed = listed || s.Name == name
+		}
+
+		if !listed {
+			res = append(res, s)
+		}
+	}
+
+	return res
+}
+
+// Resolve returns merged content; if required fields are still missing, errors of sources are returned too
+func (c *Composite) Resolve(uri string) (*Content, error) {
+	results := map[string]sourceResult{}
+	ask := func(s Source) sourceResult {
+		if r, ok := results[s.Name]; ok {
+			return r
+		}
+
+		content, err := s.Resolver.Resolve(uri)
+		if err != nil {
+			slog.Debug("source failed", "source", s.Name, "uri", uri, "error", err.Error())
+		}
+
+		results[s.Name] = sourceResult{content: content, err: err}
+		return results[s.Name]
+	}
+
+	res := &Content{Sources: map[Field]string{}}
+	for _, f := range fields {
+		for _, s := range c.order(f.name) {
+			r := ask(s)
+			if r.content != nil && f.has(r.content) {
+				f.copy(res, r.content)
+				res.Sources[f.name] = s.Name
+				break
+			}
+		}
+	}
+
+	if complete(res) {
+		return res, nil
+	}
+
+	var errs []error
+	for _, s := range c.sources {
+		if r, ok := results[s.Name]; ok && r.err != nil && !errors.Is(r.err, tags.ErrUnsupported) && !errors.Is(r.err, fs.ErrNotExist) {
+			errs = append(errs, r.err)
+		}
+	}
+
+	return res, errors.Join(errs...)
+}
+
+// Origin describes which source supplied each field
+func (c *Content) Origin() string {
+	var res []string
+	for _, f := range fields {
+		if s, ok := c.Sources[f.name]; ok {
+			res = append(res, string(f.name)+"="+s)
+		}
+	}
+
+	return strings.Join(res, " ")
+}
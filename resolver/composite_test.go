@@ -0,0 +1,126 @@
+package resolver
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type staticResolver struct {
+	content *Content
+	err     error
+	calls   *int
+}
+
+func (r staticResolver) Resolve(string) (*Content, error) {
+	if r.calls != nil {
+		*r.calls++
+	}
+
+	return r.content, r.err
+}
+
+func TestComposite_Resolve(t *testing.T) {
+	db := &Content{Artist: "db", Track: "db", Duration: 100, SampleRate: 44100, Attributes: map[AKey]int{AKeySampleRate: 44100}}
+	file := &Content{Artist: "file", Album: "file", Track: "file", Duration: 99, MusicBrainzTID: "mbid"}
+	ambiguous := &ErrAmbiguous{URI: "uri"}
+
+	tests := []struct {
+		name     string
+		db       staticResolver
+		file     staticResolver
+		priority map[Field][]string
+		want     *Content
+		wantErr  error
+	}{
+		{
+			name: "fields missing in db are taken from file",
+			db:   staticResolver{content: db},
+			file: staticResolver{content: file},
+			want: &Content{Artist: "db", Album: "file", Track: "db", Duration: 100, MusicBrainzTID: "mbid",
+				SampleRate: 44100, Attributes: map[AKey]int{AKeySampleRate: 44100},
+				Sources: map[Field]string{FieldArtist: "db", FieldAlbum: "file", FieldTrack: "db", FieldDuration: "db",
+					FieldMusicBrainzTID: "file", FieldAudio: "db"}},
+		},
+		{
+			name:     "priority",
+			db:       staticResolver{content: db},
+			file:     staticResolver{content: file},
+			priority: map[Field][]string{FieldArtist: {"file"}, FieldDuration: {"missing", "file", "db"}},
+			want: &Content{Artist: "file", Album: "file", Track: "db", Duration: 99, MusicBrainzTID: "mbid",
+				SampleRate: 44100, Attributes: map[AKey]int{AKeySampleRate: 44100},
+				Sources: map[Field]string{FieldArtist: "file", FieldAlbum: "file", FieldTrack: "db", FieldDuration: "file",
+					FieldMusicBrainzTID: "file", FieldAudio: "db"}},
+		},
+		{
+			name: "not in db",
+			db:   staticResolver{content: &Content{}},
+			file: staticResolver{content: file},
+			want: &Content{Artist: "file", Album: "file", Track: "file", Duration: 99, MusicBrainzTID: "mbid",
+				Sources: map[Field]string{FieldArtist: "file", FieldAlbum: "file", FieldTrack: "file", FieldDuration: "file",
+					FieldMusicBrainzTID: "file"}},
+		},
+		{
+			name: "ambiguous in db",
+			db:   staticResolver{content: &Content{}, err: ambiguous},
+			file: staticResolver{content: file},
+			want: &Content{Artist: "file", Album: "file", Track: "file", Duration: 99, MusicBrainzTID: "mbid",
+				Sources: map[Field]string{FieldArtist: "file", FieldAlbum: "file", FieldTrack: "file", FieldDuration: "file",
+					FieldMusicBrainzTID: "file"}},
+		},
+		{
+			name:    "ambiguous in db, no file",
+			db:      staticResolver{content: &Content{}, err: ambiguous},
+			file:    staticResolver{content: &Content{}, err: fmt.Errorf("cannot open: %w", fs.ErrNotExist)},
+			want:    &Content{Sources: map[Field]string{}},
+			wantErr: ambiguous,
+		},
+		{
+			name: "incomplete everywhere",
+			db:   staticResolver{content: &Content{Artist: "db"}},
+			file: staticResolver{content: &Content{Album: "file"}},
+			want: &Content{Artist: "db", Album: "file", Sources: map[Field]string{FieldArtist: "db", FieldAlbum: "file"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewComposite(Source{Name: "db", Resolver: tt.db}, Source{Name: "file", Resolver: tt.file})
+			for f, names := range tt.priority {
+				c.WithPriority(f, names...)
+			}
+
+			got, err := c.Resolve("uri")
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("Resolve() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestComposite_ResolveOnce(t *testing.T) {
+	var dbCalls, fileCalls int
+	c := NewComposite(
+		Source{Name: "db", Resolver: staticResolver{content: &Content{Artist: "db", Track: "db", Duration: 100}, calls: &dbCalls}},
+		Source{Name: "file", Resolver: staticResolver{content: &Content{}, calls: &fileCalls}},
+	)
+
+	got, err := c.Resolve("uri")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if dbCalls != 1 || fileCalls != 1 {
+		t.Errorf("calls = db %d, file %d, want every source asked once", dbCalls, fileCalls)
+	}
+
+	if want := "artist=db track=db duration=db"; got.Origin() != want {
+		t.Errorf("Origin() = %q, want %q", got.Origin(), want)
+	}
+}
@@ -0,0 +1,121 @@
+package resolver
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// ExtendedColumn is object_body column with extended metadata
+//
+// Column holds the value itself, or id of the row in Table with the value when Table is set,
+// the same way artist_id refers to artists.
+type ExtendedColumn struct {
+	Field  Field
+	Column string
+	Table  string
+}
+
+// ExtendedColumns of object_body, tried for album artist, disc number, genre, year and composer
+//
+// They aren't confirmed on every firmware, New selects only the ones present in database schema,
+// the rest stay empty and are filled from tags by Composite.
+var ExtendedColumns = []ExtendedColumn{
+	{Field: FieldAlbumArtist, Column: "album_artist_id", Table: "album_artists"},
+	{Field: FieldDiscNumber, Column: "disc_no"},
+	{Field: FieldGenre, Column: "genre_id", Table: "genres"},
+	{Field: FieldYear, Column: "release_date"},
+	{Field: FieldComposer, Column: "composer_id", Table: "composers"},
+}
+
+// columns returns column names of table, empty if there is no such table
+func columns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name from pragma_table_info(:table);", sql.Named("table", table))
+	if err != nil {
+		return nil, fmt.Errorf("cannot query columns of %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	res := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("cannot scan column: %w", err)
+		}
+
+		res[name] = true
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return res, nil
+}
+
+// available returns extended columns present in database schema
+func available(db *sql.DB) ([]ExtendedColumn, error) {
+	body, err := columns(db, "object_body")
+	if err != nil {
+		return nil, err
+	}
+
+	var res []ExtendedColumn
+	for _, e := range ExtendedColumns {
+		ok := body[e.Column]
+		if ok && e.Table != "" {
+			var table map[string]bool
+			if table, err = columns(db, e.Table); err != nil {
+				return nil, err
+			}
+
+			ok = table["id"] && table["value"]
+		}
+
+		if !ok {
+			slog.Info("extended metadata is not in database", "field", e.Field, "column", e.Column, "table", e.Table)
+			continue
+		}
+
+		res = append(res, e)
+	}
+
+	return res, nil
+}
+
+// objectsQuery selects objects by filename with extended columns, looked up values are joined the same way as artist
+func objectsQuery(extended []ExtendedColumn) string {
+	selects := []string{"ob.object_id", "a.value", "alb.value", "ob.title", "ob.series_no"}
+	joins := []string{"join artists a on a.id = ob.artist_id", "join albums alb on alb.id = ob.album_id"}
+	for i, e := range extended {
+		if e.Table == "" {
+			selects = append(selects, "ob."+e.Column)
+			continue
+		}
+
+		alias := fmt.Sprintf("x%d", i)
+		selects = append(selects, alias+".value")
+		joins = append(joins, fmt.Sprintf("left join %s %s on %s.id = ob.%s", e.Table, alias, alias, e.Column))
+	}
+
+	return "SELECT " + strings.Join(selects, ", ") + " from object_body ob " + strings.Join(joins, " ") +
+		" where ob.filename = :filename;"
+}
+
+// setExtended sets field of content to database value
+func setExtended(c *Content, f Field, value string) {
+	value = strings.TrimSpace(value)
+	switch f {
+	case FieldAlbumArtist:
+		c.AlbumArtist = value
+	case FieldDiscNumber:
+		c.DiscNumber = value
+	case FieldGenre:
+		c.Genre = value
+	case FieldYear:
+		c.Year = Year(value)
+	case FieldComposer:
+		c.Composer = value
+	}
+}
@@ -0,0 +1,51 @@
+package resolver
+
+import (
+	"scrobbler/tags"
+	"strconv"
+	"time"
+)
+
+// FileResolver reads tags from the file at uri
+//
+// Tags are the fallback for album artist, disc number, genre, year and composer when media database
+// has no columns for them, see ExtendedColumns.
+type FileResolver struct{}
+
+func (FileResolver) Resolve(uri string) (*Content, error) {
+	t, err := tags.Read(uri)
+	if err != nil {
+		return &Content{}, err
+	}
+
+	return &Content{
+		Artist:         t.Artist,
+		Album:          t.Album,
+		Track:          t.Title,
+		TrackNumber:    t.TrackNumber,
+		MusicBrainzTID: t.MusicBrainzTID,
+		AlbumArtist:    t.AlbumArtist,
+		DiscNumber:     t.DiscNumber,
+		Genre:          t.Genre,
+		Year:           Year(t.Date),
+		Composer:       t.Composer,
+		Duration:       uint(t.Duration / time.Second),
+		SampleRate:     t.SampleRate,
+		Channels:       t.Channels,
+		BitDepth:       t.BitDepth,
+	}, nil
+}
+
+// Year takes year from the start of date like "2004", "2004-05-17" or "20040517"
+func Year(date string) int {
+	if len(date) < 4 {
+		return 0
+	}
+
+	y, err := strconv.Atoi(date[:4])
+	if err != nil || y <= 0 {
+		return 0
+	}
+
+	return y
+}
@@ -0,0 +1,178 @@
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"scrobbler/device"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SourceOverride is recorded in Content.Sources for corrected fields
+const SourceOverride = "override"
+
+// Correction holds corrected values, empty values are not changed
+type Correction struct {
+	Artist string `json:"artist,omitempty"`
+	Album  string `json:"album,omitempty"`
+	Title  string `json:"title,omitempty"`
+}
+
+// Override matches content by all non-empty fields
+type Override struct {
+	Path   string     `json:"path,omitempty"`   // uri prefix or glob
+	Artist string     `json:"artist,omitempty"` // resolved artist, case-insensitive
+	Album  string     `json:"album,omitempty"`  // resolved album, case-insensitive
+	Set    Correction `json:"set"`
+}
+
+func (o Override) Match(uri string, c *Content) bool {
+	if !device.MatchPath(o.Path, uri) {
+		return false
+	}
+
+	if o.Artist != "" && !strings.EqualFold(o.Artist, c.Artist) {
+		return false
+	}
+
+	if o.Album != "" && !strings.EqualFold(o.Album, c.Album) {
+		return false
+	}
+
+	return true
+}
+
+// Overrides corrects resolved content with user-editable file, file is reloaded when it changes
+//
+// All matching overrides are applied in file order, so later ones win.
+type Overrides struct {
+	resolver  Resolver
+	filename  string
+	mtime     time.Time
+	overrides []Override
+	lock      sync.Mutex
+}
+
+func NewOverrides(r Resolver, filename string) *Overrides {
+	return &Overrides{resolver: r, filename: filename}
+}
+
+func (o *Overrides) Resolve(uri string) (*Content, error) {
+	c, err := o.resolver.Resolve(uri)
+	if c == nil {
+		return c, err
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+
+	o.reload()
+
+	var res *Content
+	for _, ov := range o.overrides {
+		if !ov.Match(uri, c) {
+			continue
+		}
+
+		if res == nil {
+			// content may be shared with wrapped resolver
+			cl := c.clone()
+			res = &cl
+		}
+
+		o.apply(res, ov.Set)
+	}
+
+	if res == nil {
+		return c, err
+	}
+
+	return res, err
+}
+
+func (o *Overrides) apply(c *Content, set Correction) {
+	if c.Sources == nil {
+		c.Sources = map[Field]string{}
+	}
+
+	if set.Artist != "" {
+		c.Artist = set.Artist
+		c.Sources[FieldArtist] = SourceOverride
+	}
+
+	if set.Album != "" {
+		c.Album = set.Album
+		c.Sources[FieldAlbum] = SourceOverride
+	}
+
+	if set.Title != "" {
+		c.Track = set.Title
+		c.Sources[FieldTrack] = SourceOverride
+	}
+}
+
+// reload reads file if it has been modified, invalid file keeps previous overrides
+func (o *Overrides) reload() {
+	info, err := os.Stat(o.filename)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Error("cannot stat overrides", "error", err.Error())
+			return
+		}
+
+		if o.overrides != nil {
+			slog.Info("overrides removed")
+		}
+
+		o.overrides = nil
+		o.mtime = time.Time{}
+		return
+	}
+
+	if info.ModTime().Equal(o.mtime) {
+		return
+	}
+
+	o.mtime = info.ModTime()
+
+	overrides, err := LoadOverrides(o.filename)
+	if err != nil {
+		slog.Error("cannot load overrides, keeping previous", "error", err.Error())
+		return
+	}
+
+	slog.Info("overrides loaded", "count", len(overrides))
+	o.overrides = overrides
+}
+
+// LoadOverrides reads overrides from file
+func LoadOverrides(filename string) ([]Override, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read overrides: %w", err)
+	}
+
+	f := struct {
+		Overrides []Override `json:"overrides"`
+	}{}
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal overrides: %w", err)
+	}
+
+	for i, ov := range f.Overrides {
+		if ov.Path == "" && ov.Artist == "" && ov.Album == "" {
+			return nil, fmt.Errorf("override %d matches everything, set path, artist or album", i+1)
+		}
+	}
+
+	if f.Overrides == nil {
+		f.Overrides = []Override{}
+	}
+
+	return f.Overrides, nil
+}
@@ -0,0 +1,128 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestOverrides_Resolve(t *testing.T) {
+	content := &Content{Artist: "ÃÂ¢ÃÂ", Album: "Album", Track: "Track", Duration: 100}
+
+	tests := []struct {
+		name string
+		file string
+		uri  string
+		want *Content
+	}{
+		{
+			name: "artist and album",
+			file: `{"overrides": [{"artist": "ãâ¢ãâ", "album": "album", "set": {"artist": "Artist"}}]}`,
+			uri:  "/data/mnt/internal/MUSIC/1.flac",
+			want: &Content{Artist: "Artist", Album: "Album", Track: "Track", Duration: 100,
+				Sources: map[Field]string{FieldArtist: SourceOverride}},
+		},
+		{
+			name: "path glob",
+			file: `{"overrides": [{"path": "/data/mnt/internal/MUSIC/*.flac", "set": {"album": "Fixed", "title": "Title"}}]}`,
+			uri:  "/data/mnt/internal/MUSIC/1.flac",
+			want: &Content{Artist: "ÃÂ¢ÃÂ", Album: "Fixed", Track: "Title", Duration: 100,
+				Sources: map[Field]string{FieldAlbum: SourceOverride, FieldTrack: SourceOverride}},
+		},
+		{
+			name: "later override wins",
+			file: `{"overrides": [{"path": "/data/mnt/internal/", "set": {"album": "First"}}, {"album": "Album", "set": {"album": "Second"}}]}`,
+			uri:  "/data/mnt/internal/MUSIC/1.flac",
+			want: &Content{Artist: "ÃÂ¢ÃÂ", Album: "Second", Track: "Track", Duration: 100,
+				Sources: map[Field]string{FieldAlbum: SourceOverride}},
+		},
+		{
+			name: "no match",
+			file: `{"overrides": [{"path": "/contents_ext/", "set": {"artist": "Artist"}}]}`,
+			uri:  "/data/mnt/internal/MUSIC/1.flac",
+			want: content,
+		},
+		{
+			name: "invalid file",
+			file: `{"overrides": [`,
+			uri:  "/data/mnt/internal/MUSIC/1.flac",
+			want: content,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "overrides.json")
+			if err := os.WriteFile(filename, []byte(tt.file), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := NewOverrides(staticResolver{content: content}, filename).Resolve(tt.uri)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+			}
+
+			if content.Artist != "ÃÂ¢ÃÂ" || content.Sources != nil {
+				t.Errorf("resolved content has been modified: %+v", content)
+			}
+		})
+	}
+}
+
+func TestOverrides_Reload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "overrides.json")
+	o := NewOverrides(staticResolver{content: &Content{Artist: "artist", Album: "album"}}, filename)
+
+	write := func(data string, mtime time.Time) {
+		if err := os.WriteFile(filename, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chtimes(filename, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	artist := func() string {
+		c, err := o.Resolve("uri")
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+
+		return c.Artist
+	}
+
+	if got := artist(); got != "artist" {
+		t.Errorf("no file: artist = %v, want %v", got, "artist")
+	}
+
+	now := time.Now()
+	write(`{"overrides": [{"album": "album", "set": {"artist": "first"}}]}`, now)
+	if got := artist(); got != "first" {
+		t.Errorf("created: artist = %v, want %v", got, "first")
+	}
+
+	write(`{"overrides": [{"album": "album", "set": {"artist": "second"}}]}`, now.Add(time.Second))
+	if got := artist(); got != "second" {
+		t.Errorf("modified: artist = %v, want %v", got, "second")
+	}
+
+	write(`{"overrides": [{"set": {"artist": "everything"}}]}`, now.Add(2*time.Second))
+	if got := artist(); got != "second" {
+		t.Errorf("invalid: artist = %v, want previous %v", got, "second")
+	}
+
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := artist(); got != "artist" {
+		t.Errorf("removed: artist = %v, want %v", got, "artist")
+	}
+}
@@ -0,0 +1,156 @@
+package resolver
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"scrobbler/device"
+	"strconv"
+	"strings"
+)
+
+// Patterns are default path templates, the first matching one is used
+//
+// Template matches whole uri relative to storage root, so storage folders are never taken for artist or album;
+// placeholders are {artist}, {album}, {track} (number), {title} and {ext}.
+// Files directly under MUSIC or one folder deep have no artist in path, only number and title are taken.
+var Patterns = []string{
+	"MUSIC/{artist}/{album}/{track} - {title}.{ext}",
+	"MUSIC/{artist}/{album}/{track}. {title}.{ext}",
+	"MUSIC/{artist}/{album}/{track} {title}.{ext}",
+	"MUSIC/{album}/{track} - {title}.{ext}",
+	"MUSIC/{album}/{track}. {title}.{ext}",
+	"MUSIC/{album}/{track} {title}.{ext}",
+	"MUSIC/{track} - {title}.{ext}",
+	"MUSIC/{track}. {title}.{ext}",
+	"MUSIC/{track} {title}.{ext}",
+	"MUSIC/{artist} - {title}.{ext}",
+}
+
+var placeholders = map[string]string{
+	"artist": `[^/]+?`,
+	"album":  `[^/]+?`,
+	"track":  `\d+`,
+	"title":  `[^/]+?`,
+	"ext":    `[^/.]+`,
+}
+
+var placeholder = regexp.MustCompile(`\{([a-z]+)\}`)
+
+// PatternResolver derives content from file path for files without tags
+type PatternResolver struct {
+	patterns []*regexp.Regexp
+}
+
+func NewPatternResolver(templates ...string) (*PatternResolver, error) {
+	r := &PatternResolver{}
+	for _, t := range templates {
+		re, err := compile(t)
+		if err != nil {
+			return nil, err
+		}
+
+		r.patterns = append(r.patterns, re)
+	}
+
+	return r, nil
+}
+
+// compile converts template to regular expression matching whole relative uri
+func compile(template string) (*regexp.Regexp, error) {
+	var expr strings.Builder
+	expr.WriteString(`^`)
+
+	rest := template
+	for {
+		loc := placeholder.FindStringSubmatchIndex(rest)
+		if loc == nil {
+			expr.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+
+		name := rest[loc[2]:loc[3]]
+		p, ok := placeholders[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown placeholder {%s} in pattern %q", name, template)
+		}
+
+		expr.WriteString(regexp.QuoteMeta(rest[:loc[0]]))
+		fmt.Fprintf(&expr, "(?P<%s>%s)", name, p)
+		rest = rest[loc[1]:]
+	}
+
+	expr.WriteString(`$`)
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil, fmt.Errorf("cannot compile pattern %q: %w", template, err)
+	}
+
+	return re, nil
+}
+
+func (r *PatternResolver) Resolve(uri string) (*Content, error) {
+	rel := device.Relative(uri)
+	for _, re := range r.patterns {
+		m := re.FindStringSubmatch(rel)
+		if m == nil {
+			continue
+		}
+
+		c := &Content{}
+		for i, name := range re.SubexpNames() {
+			value := strings.TrimSpace(m[i])
+			switch name {
+			case "artist":
+				c.Artist = value
+			case "album":
+				c.Album = value
+			case "title":
+				c.Track = value
+			case "track":
+				if n, err := strconv.Atoi(value); err == nil {
+					c.TrackNumber = strconv.Itoa(n)
+				}
+			}
+		}
+
+		return c, nil
+	}
+
+	return &Content{}, nil
+}
+
+// LoadPatterns reads templates from file, one per line, lines starting with # are ignored;
+// default patterns are used if there is no file
+func LoadPatterns(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Patterns, nil
+		}
+
+		return Patterns, fmt.Errorf("cannot open patterns: %w", err)
+	}
+	defer f.Close()
+
+	var res []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		res = append(res, line)
+	}
+
+	if err = s.Err(); err != nil {
+		return Patterns, fmt.Errorf("cannot read patterns: %w", err)
+	}
+
+	return res, nil
+}
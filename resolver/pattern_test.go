@@ -0,0 +1,132 @@
+package resolver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPatternResolver_Resolve(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		uri      string
+		want     *Content
+	}{
+		{
+			name:     "artist, album, number and title",
+			patterns: Patterns,
+			uri:      "/data/mnt/internal/MUSIC/Enigma/MCMXC a.D./02 - Sadeness (Meditation).ape",
+			want:     &Content{Artist: "Enigma", Album: "MCMXC a.D.", Track: "Sadeness (Meditation)", TrackNumber: "2"},
+		},
+		{
+			name:     "number with dot",
+			patterns: Patterns,
+			uri:      "/data/mnt/internal/MUSIC/AC-DC/The Razors Edge/01. Thunderstruck.mp3",
+			want:     &Content{Artist: "AC-DC", Album: "The Razors Edge", Track: "Thunderstruck", TrackNumber: "1"},
+		},
+		{
+			name:     "artist and title",
+			patterns: Patterns,
+			uri:      "/data/mnt/internal/MUSIC/Shantel - Bucovina - Haaksman Mix.mp3",
+			want:     &Content{Artist: "Shantel", Track: "Bucovina - Haaksman Mix"},
+		},
+		{
+			name:     "no match",
+			patterns: Patterns,
+			uri:      "/data/mnt/internal/MUSIC/Snowflake.flac",
+			want:     &Content{},
+		},
+		{
+			name:     "directly under MUSIC, storage is not artist",
+			patterns: Patterns,
+			uri:      "/data/mnt/internal/MUSIC/03 - Bucovina [Haaksman & Haaksman Soca Bogle Mix] - Shantel.mp3",
+			want:     &Content{Track: "Bucovina [Haaksman & Haaksman Soca Bogle Mix] - Shantel", TrackNumber: "3"},
+		},
+		{
+			name:     "directly under MUSIC without separator",
+			patterns: Patterns,
+			uri:      "/data/mnt/internal/MUSIC/07 The Voice & The Snake.flac",
+			want:     &Content{Track: "The Voice & The Snake", TrackNumber: "7"},
+		},
+		{
+			name:     "one folder deep, MUSIC is not artist",
+			patterns: Patterns,
+			uri:      "/contents_ext/MUSIC/Album/01 Song.flac",
+			want:     &Content{Album: "Album", Track: "Song", TrackNumber: "1"},
+		},
+		{
+			name:     "too deep",
+			patterns: Patterns,
+			uri:      "/data/mnt/internal/MUSIC/Collection/Artist/Album/01 - Song.flac",
+			want:     &Content{},
+		},
+		{
+			name:     "unknown storage",
+			patterns: Patterns,
+			uri:      "/tmp/MUSIC/Artist/Album/01 - Song.flac",
+			want:     &Content{},
+		},
+		{
+			name:     "custom pattern",
+			patterns: []string{"MUSIC/{album} ({artist})/{track}_{title}.{ext}"},
+			uri:      "/contents_ext/MUSIC/Live (Band)/07_Song.flac",
+			want:     &Content{Artist: "Band", Album: "Live", Track: "Song", TrackNumber: "7"},
+		},
+		{
+			name:     "custom pattern is anchored at folder",
+			patterns: []string{"MUSIC/{album} ({artist})/{track}_{title}.{ext}"},
+			uri:      "/contents_ext/OTHER_MUSIC/Live (Band)/07_Song.flac",
+			want:     &Content{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewPatternResolver(tt.patterns...)
+			if err != nil {
+				t.Fatalf("NewPatternResolver() error = %v", err)
+			}
+
+			got, err := r.Resolve(tt.uri)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNewPatternResolver_unknownPlaceholder(t *testing.T) {
+	if _, err := NewPatternResolver("{artist}/{year}/{title}.{ext}"); err == nil {
+		t.Errorf("NewPatternResolver() error = nil, want unknown placeholder")
+	}
+}
+
+func TestLoadPatterns(t *testing.T) {
+	dir := t.TempDir()
+
+	got, err := LoadPatterns(filepath.Join(dir, "missing"))
+	if err != nil || len(got) != len(Patterns) {
+		t.Errorf("LoadPatterns() = %v, %v, want defaults", got, err)
+	}
+
+	filename := filepath.Join(dir, "patterns")
+	data := "# mine\n\nMUSIC/{artist}/{title}.{ext}\n  MUSIC/{artist} - {title}.{ext}  \n"
+	if err = os.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = LoadPatterns(filename)
+	if err != nil {
+		t.Fatalf("LoadPatterns() error = %v", err)
+	}
+
+	if diff := cmp.Diff([]string{"MUSIC/{artist}/{title}.{ext}", "MUSIC/{artist} - {title}.{ext}"}, got); diff != "" {
+		t.Errorf("LoadPatterns() mismatch (-want +got):\n%s", diff)
+	}
+}
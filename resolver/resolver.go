@@ -2,10 +2,14 @@ package resolver
 
 import (
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log/slog"
+	"maps"
 	_ "modernc.org/sqlite"
 	"path"
+	"scrobbler/device"
+	"slices"
 	"strings"
 )
 
@@ -29,10 +33,17 @@ type Content struct {
 	Rating         bool // true if listened more than 50% of duration
 	StartedAt      int64
 	MusicBrainzTID string
+	AlbumArtist    string
+	DiscNumber     string
+	Genre          string
+	Year           int
+	Composer       string
 	SampleRate     int
 	Bitrate        int
 	Channels       int
 	BitDepth       int
+	Attributes     map[AKey]int     // raw object_ext_int values by akey
+	Sources        map[Field]string // name of the source of each field, set by Composite
 	Attempted      bool
 }
 
@@ -41,7 +52,6 @@ type DBContent struct {
 	Album       sql.NullString
 	Track       sql.NullString
 	TrackNumber sql.NullString
-	Duration    sql.NullInt32
 }
 
 var Skipped = "S"
@@ -49,11 +59,42 @@ var Listened = "L"
 
 // Valid checks if fields required for scrobbler are filled
 func (c *Content) Valid() bool {
-	if c.Artist == "" || c.Track == "" || c.Duration == 0 || c.StartedAt == 0 {
-		return false
+	return len(c.Missing()) == 0
+}
+
+// Missing returns names of fields required for scrobbler which are not filled
+func (c *Content) Missing() []string {
+	var res []string
+	if c.Artist == "" {
+		res = append(res, "artist")
+	}
+
+	if c.Track == "" {
+		res = append(res, "track")
 	}
 
-	return true
+	if c.Duration == 0 {
+		res = append(res, "duration")
+	}
+
+	if c.StartedAt == 0 {
+		res = append(res, "startedAt")
+	}
+
+	return res
+}
+
+// complete checks fields required for scrobbler which are provided by resolver
+func complete(c *Content) bool {
+	return c.Artist != "" && c.Track != "" && c.Duration > 0
+}
+
+// clone returns copy which doesn't share maps with c
+func (c *Content) clone() Content {
+	res := *c
+	res.Attributes = maps.Clone(c.Attributes)
+	res.Sources = maps.Clone(c.Sources)
+	return res
 }
 
 // produces scrobbler-compatible string
@@ -63,7 +104,7 @@ func (c *Content) String() string {
 		rating = Listened
 	}
 
-	return fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%s\t%d\t",
+	return fmt.Sprintf("%s\t%s\t%s\t%s\t%d\t%s\t%d\t%s",
 		strings.ReplaceAll(c.Artist, "\t", ""),
 		strings.ReplaceAll(c.Album, "\t", ""),
 		strings.ReplaceAll(c.Track, "\t", ""),
@@ -71,22 +112,70 @@ func (c *Content) String() string {
 		c.Duration,
 		rating,
 		c.StartedAt,
+		strings.ReplaceAll(c.MusicBrainzTID, "\t", ""),
 	)
 }
 
+// AKey is attribute key in object_ext_int table
+type AKey int
+
+const (
+	AKeyDuration   AKey = 12 // milliseconds
+	AKeySampleRate AKey = 16
+	AKeyChannels   AKey = 17
+	AKeyBitrate    AKey = 19
+	AKeyBitDepth   AKey = 78
+)
+
+// AKeys are attributes fetched for every resolved object
+var AKeys = []AKey{AKeyDuration, AKeySampleRate, AKeyChannels, AKeyBitrate, AKeyBitDepth}
+
 type DBResolver struct {
-	db *sql.DB
+	db         *sql.DB
+	objects    *sql.Stmt
+	parents    *sql.Stmt
+	attributes *sql.Stmt
+	extended   []ExtendedColumn // extended columns present in database
+}
+
+// Candidate is one of the objects matching uri
+type Candidate struct {
+	ObjectID int32
+	Path     string // titles of parent objects and filename
+	Artist   string
+	Track    string
+}
+
+// ErrAmbiguous is returned when several objects match uri equally well
+type ErrAmbiguous struct {
+	URI        string
+	Candidates []Candidate
+}
+
+func (e *ErrAmbiguous) Error() string {
+	res := make([]string, len(e.Candidates))
+	for i, c := range e.Candidates {
+		res[i] = fmt.Sprintf("%d %s (%s - %s)", c.ObjectID, c.Path, c.Artist, c.Track)
+	}
+
+	return fmt.Sprintf("ambiguous uri %s, candidates: %s", e.URI, strings.Join(res, ", "))
 }
 
+// MaxDepth limits parent chain of object, protects from loops in database
+var MaxDepth = 32
+
 type Resolver interface {
 	Resolve(string) (*Content, error)
 }
 
+// DBPath is the device media database
+var DBPath = "/db/MTPDB.dat"
+
 func New() (*DBResolver, error) {
 	r := &DBResolver{}
 	var err error
 
-	r.db, err = sql.Open("sqlite", "/db/MTPDB.dat")
+	r.db, err = sql.Open("sqlite", DBPath)
 	if err != nil {
 		return nil, fmt.Errorf("cannot open db: %w", err)
 	}
@@ -96,75 +185,217 @@ func New() (*DBResolver, error) {
 		return nil, fmt.Errorf("cannot ping db: %w", err)
 	}
 
+	r.extended, err = available(r.db)
+	if err != nil {
+		return nil, fmt.Errorf("cannot check extended columns: %w", err)
+	}
+
+	r.objects, err = r.db.Prepare(objectsQuery(r.extended))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare object query: %w", err)
+	}
+
+	// chains of all objects in :object_ids json array, each row carries id of the object it started from
+	r.parents, err = r.db.Prepare("WITH RECURSIVE chain(origin, parent_id, title, depth) AS (" +
+		"SELECT object_id, parent_id, NULL, 0 from object_body where object_id in (SELECT value from json_each(:object_ids)) " +
+		"UNION ALL SELECT c.origin, ob.parent_id, ob.title, c.depth + 1 from object_body ob " +
+		"join chain c on ob.object_id = c.parent_id where c.depth < :depth) " +
+		"SELECT origin, title from chain where depth > 0 order by origin, depth;")
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare parents query: %w", err)
+	}
+
+	r.attributes, err = r.db.Prepare(attributesQuery(AKeys))
+	if err != nil {
+		return nil, fmt.Errorf("cannot prepare attributes query: %w", err)
+	}
+
 	return r, nil
 }
 
-func (r *DBResolver) GetFileParams(objectId int32, akey int) (int, error) {
-	rows, err := r.db.Query("SELECT value from object_ext_int where object_id = :object_id and akey= :akey",
-		sql.Named("object_id", objectId), sql.Named("akey", akey),
-	)
+// attributesQuery pivots object_ext_int rows of object into a single row, one column per akey
+func attributesQuery(akeys []AKey) string {
+	columns := make([]string, len(akeys))
+	for i, k := range akeys {
+		columns[i] = fmt.Sprintf("max(case when akey = %d then value end)", k)
+	}
+
+	return "SELECT " + strings.Join(columns, ", ") + " from object_ext_int where object_id = :object_id;"
+}
+
+// Attributes returns object_ext_int values of object, missing attributes are not included
+func (r *DBResolver) Attributes(objectId int32) (map[AKey]int, error) {
+	values := make([]sql.NullInt64, len(AKeys))
+	dest := make([]any, len(AKeys))
+	for i := range values {
+		dest[i] = &values[i]
+	}
 
+	err := r.attributes.QueryRow(sql.Named("object_id", objectId)).Scan(dest...)
 	if err != nil {
-		return 0, fmt.Errorf("cannot query db: %w", err)
+		return nil, fmt.Errorf("cannot query attributes: %w", err)
+	}
+
+	res := map[AKey]int{}
+	for i, v := range values {
+		if v.Valid {
+			res[AKeys[i]] = int(v.Int64)
+		}
+	}
+
+	return res, nil
+}
+
+// Parents returns titles of parents of each object by object id, closest parent first
+func (r *DBResolver) Parents(objectIds ...int32) (map[int32][]string, error) {
+	ids, err := json.Marshal(objectIds)
+	if err != nil {
+		return nil, fmt.Errorf("cannot encode object ids: %w", err)
+	}
+
+	rows, err := r.parents.Query(sql.Named("object_ids", string(ids)), sql.Named("depth", MaxDepth))
+	if err != nil {
+		return nil, fmt.Errorf("cannot query parents: %w", err)
 	}
 	defer rows.Close()
 
-	var result sql.NullInt32
+	res := map[int32][]string{}
 	for rows.Next() {
-		if err = rows.Scan(&result); err != nil {
-			return 0, fmt.Errorf("cannot scan: %w", err)
+		var origin int32
+		var title sql.NullString
+		if err = rows.Scan(&origin, &title); err != nil {
+			return nil, fmt.Errorf("cannot scan parent: %w", err)
 		}
+
+		res[origin] = append(res[origin], title.String)
 	}
 
-	return int(result.Int32), nil
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return res, nil
+}
+
+// matched returns amount of directories matched by parents, starting from the closest one
+func matched(parents []string, dirs []string) int {
+	res := 0
+	for res < len(parents) && res < len(dirs) && parents[res] == dirs[res] {
+		res++
+	}
+
+	return res
 }
 
 // Resolve takes uri string and returns content metadata for that uri
-// content is matched on filename and its parent directory
+//
+// Objects with uri filename are matched by their parents against uri directories, object with the longest
+// matching chain wins. Objects which don't match even the closest directory are not considered.
+//
+// Directories are taken relative to storage root, so SD card uris (/contents_ext/...) are matched the same way
+// as internal ones. Database has no known storage column: the same path on both storages is ambiguous
+// and left to the file resolver, which reads the file on the right storage.
 func (r *DBResolver) Resolve(uri string) (*Content, error) {
-	dir, filename := path.Split(uri)
-	directory := path.Base(dir)
-
-	rows, err := r.db.Query("SELECT ob.object_id, a.value, alb.value, ob.title, ob.series_no, info.value from object_body ob "+
-		"join artists a on a.id = ob.artist_id "+
-		"join albums alb on alb.id = ob.album_id "+
-		"join object_ext_int info on info.object_id = ob.object_id "+
-		"join object_body ob2 on ob2.object_id = ob.parent_id "+
-		"where ob.filename = :filename and ob2.title = :directory and info.akey=12;",
-		sql.Named("filename", filename), sql.Named("directory", directory),
-	)
+	dir, filename := path.Split(device.Relative(uri))
+
+	dirs := strings.Split(strings.Trim(dir, "/"), "/")
+	slices.Reverse(dirs)
 
+	rows, err := r.objects.Query(sql.Named("filename", filename))
 	if err != nil {
 		return nil, fmt.Errorf("cannot query db: %w", err)
 	}
 	defer rows.Close()
 
-	dbc := &DBContent{}
-	var objectId sql.NullInt32
+	type object struct {
+		id       int32
+		content  DBContent
+		extended []sql.NullString
+		parents  []string
+	}
 
+	var objects []object
 	for rows.Next() {
-		if err = rows.Scan(&objectId, &dbc.Artist, &dbc.Album, &dbc.Track, &dbc.TrackNumber, &dbc.Duration); err != nil {
+		o := object{extended: make([]sql.NullString, len(r.extended))}
+		dest := []any{&o.id, &o.content.Artist, &o.content.Album, &o.content.Track, &o.content.TrackNumber}
+		for i := range o.extended {
+			dest = append(dest, &o.extended[i])
+		}
+
+		if err = rows.Scan(dest...); err != nil {
 			return &Content{}, fmt.Errorf("cannot scan row: %w", err)
 		}
+
+		objects = append(objects, o)
 	}
 
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows error: %w", err)
 	}
 
+	rows.Close()
+
+	ids := make([]int32, len(objects))
+	for i, o := range objects {
+		ids[i] = o.id
+	}
+
+	parents, err := r.Parents(ids...)
+	if err != nil {
+		return nil, err
+	}
+
+	var best []object
+	bestMatch := 0
+	for _, o := range objects {
+		o.parents = parents[o.id]
+		m := matched(o.parents, dirs)
+		switch {
+		case m == 0 || m < bestMatch:
+			// other object is closer
+		case m > bestMatch:
+			bestMatch = m
+			best = []object{o}
+		default:
+			best = append(best, o)
+		}
+	}
+
+	if len(best) > 1 {
+		e := &ErrAmbiguous{URI: uri}
+		for _, o := range best {
+			chain := slices.Clone(o.parents)
+			slices.Reverse(chain)
+			e.Candidates = append(e.Candidates, Candidate{
+				ObjectID: o.id,
+				Path:     path.Join(append(chain, filename)...),
+				Artist:   o.content.Artist.String,
+				Track:    o.content.Track.String,
+			})
+		}
+
+		return &Content{}, e
+	}
+
 	c := &Content{}
-	if dbc.Artist.Valid {
-		c.Artist = dbc.Artist.String
+	if len(best) == 0 {
+		return c, nil
 	}
 
-	if dbc.Duration.Valid {
-		c.Duration = uint(dbc.Duration.Int32) / 1000 // to seconds
+	dbc := best[0].content
+	if dbc.Artist.Valid {
+		c.Artist = dbc.Artist.String
 	}
 
 	if dbc.Track.Valid {
 		c.Track = dbc.Track.String
 	}
 
+	// untagged file gets its name as title, it is not a title
+	if c.Artist == "" && (c.Track == filename || c.Track == strings.TrimSuffix(filename, path.Ext(filename))) {
+		c.Track = ""
+	}
+
 	if dbc.Album.Valid {
 		c.Album = dbc.Album.String
 	}
@@ -173,28 +404,23 @@ func (r *DBResolver) Resolve(uri string) (*Content, error) {
 		c.TrackNumber = dbc.TrackNumber.String
 	}
 
-	if objectId.Valid {
-		c.SampleRate, err = r.GetFileParams(objectId.Int32, 16)
-		if err != nil {
-			slog.Error("failed to get sample rate", "error", err.Error(), "object_id", objectId.Int32)
-		}
-
-		c.Bitrate, err = r.GetFileParams(objectId.Int32, 19)
-		if err != nil {
-			slog.Error("failed to get bitrate", "error", err.Error(), "object_id", objectId.Int32)
-		}
-
-		c.Channels, err = r.GetFileParams(objectId.Int32, 17)
-		if err != nil {
-			slog.Error("failed to get channels", "error", err.Error(), "object_id", objectId.Int32)
+	for i, v := range best[0].extended {
+		if v.Valid {
+			setExtended(c, r.extended[i].Field, v.String)
 		}
+	}
 
-		c.BitDepth, err = r.GetFileParams(objectId.Int32, 78)
-		if err != nil {
-			slog.Error("failed to get bit depth", "error", err.Error(), "object_id", objectId.Int32)
-		}
+	c.Attributes, err = r.Attributes(best[0].id)
+	if err != nil {
+		slog.Error("failed to get attributes", "error", err.Error(), "object_id", best[0].id)
 	}
 
+	c.Duration = uint(c.Attributes[AKeyDuration]) / 1000 // to seconds
+	c.SampleRate = c.Attributes[AKeySampleRate]
+	c.Bitrate = c.Attributes[AKeyBitrate]
+	c.Channels = c.Attributes[AKeyChannels]
+	c.BitDepth = c.Attributes[AKeyBitDepth]
+
 	c.Rating = false
 
 	return c, nil
@@ -0,0 +1,317 @@
+package resolver
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+// testDB creates media database with the parts of device schema used by resolver
+func testDB(t *testing.T, statements ...string) string {
+	t.Helper()
+
+	filename := filepath.Join(t.TempDir(), "MTPDB.dat")
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	schema := []string{
+		"CREATE TABLE object_body (object_id INTEGER PRIMARY KEY, parent_id INTEGER, artist_id INTEGER, album_id INTEGER, " +
+			"title TEXT, series_no TEXT, filename TEXT);",
+		"CREATE TABLE artists (id INTEGER PRIMARY KEY, value TEXT);",
+		"CREATE TABLE albums (id INTEGER PRIMARY KEY, value TEXT);",
+		"CREATE TABLE object_ext_int (object_id INTEGER, akey INTEGER, value INTEGER);",
+	}
+
+	for _, s := range append(schema, statements...) {
+		if _, err = db.Exec(s); err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+	}
+
+	return filename
+}
+
+func TestDBResolver_Resolve(t *testing.T) {
+	DBPath = testDB(t,
+		"INSERT INTO artists VALUES (1, 'artist'), (2, 'other artist'), (3, '');",
+		"INSERT INTO albums VALUES (1, 'album');",
+		"INSERT INTO object_body VALUES (1, 0, 1, 1, 'album', NULL, NULL);",
+		"INSERT INTO object_body VALUES (2, 1, 1, 1, 'track', '3', '3.flac');",
+		"INSERT INTO object_body VALUES (3, 1, 1, 1, 'no attributes', '4', '4.flac');",
+		"INSERT INTO object_body VALUES (4, 1, 3, 1, '05 untagged', NULL, '05 untagged.mp3');",
+		"INSERT INTO object_ext_int VALUES (2, 12, 215000), (2, 16, 96000), (2, 17, 2), (2, 19, 2304000), (2, 78, 24), (2, 99, 1);",
+		"INSERT INTO object_body VALUES (10, 0, 1, 1, 'MUSIC', NULL, NULL), (11, 10, 1, 1, 'A', NULL, NULL), "+
+			"(12, 10, 2, 1, 'B', NULL, NULL), (13, 11, 1, 1, 'Greatest Hits', NULL, NULL), (14, 12, 2, 1, 'Greatest Hits', NULL, NULL);",
+		"INSERT INTO object_body VALUES (15, 13, 1, 1, 'first', '1', '01.flac'), (16, 14, 2, 1, 'second', '1', '01.flac');",
+		"INSERT INTO object_body VALUES (17, 10, 1, 1, 'Dup', NULL, NULL), (18, 17, 1, 1, 'one', '2', '02.flac'), "+
+			"(19, 17, 2, 1, 'two', '2', '02.flac');",
+	)
+
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		uri           string
+		want          *Content
+		wantAmbiguous []int32
+	}{
+		{
+			name: "found",
+			uri:  "/data/mnt/internal/MUSIC/album/3.flac",
+			want: &Content{
+				Artist:      "artist",
+				Album:       "album",
+				Track:       "track",
+				TrackNumber: "3",
+				Duration:    215,
+				SampleRate:  96000,
+				Bitrate:     2304000,
+				Channels:    2,
+				BitDepth:    24,
+				Attributes: map[AKey]int{
+					AKeyDuration:   215000,
+					AKeySampleRate: 96000,
+					AKeyChannels:   2,
+					AKeyBitrate:    2304000,
+					AKeyBitDepth:   24,
+				},
+			},
+		},
+		{
+			name: "no attributes",
+			uri:  "/data/mnt/internal/MUSIC/album/4.flac",
+			want: &Content{
+				Artist:      "artist",
+				Album:       "album",
+				Track:       "no attributes",
+				TrackNumber: "4",
+				Attributes:  map[AKey]int{},
+			},
+		},
+		{
+			name: "untagged file name is not a title",
+			uri:  "/data/mnt/internal/MUSIC/album/05 untagged.mp3",
+			want: &Content{
+				Album:      "album",
+				Attributes: map[AKey]int{},
+			},
+		},
+		{
+			name: "same directory title, different parents",
+			uri:  "/data/mnt/internal/MUSIC/B/Greatest Hits/01.flac",
+			want: &Content{
+				Artist:      "other artist",
+				Album:       "album",
+				Track:       "second",
+				TrackNumber: "1",
+				Attributes:  map[AKey]int{},
+			},
+		},
+		{
+			name: "sd card",
+			uri:  "/contents_ext/MUSIC/A/Greatest Hits/01.flac",
+			want: &Content{
+				Artist:      "artist",
+				Album:       "album",
+				Track:       "first",
+				TrackNumber: "1",
+				Attributes:  map[AKey]int{},
+			},
+		},
+		{
+			name:          "ambiguous",
+			uri:           "/data/mnt/internal/MUSIC/Dup/02.flac",
+			want:          &Content{},
+			wantAmbiguous: []int32{18, 19},
+		},
+		{
+			name: "not found",
+			uri:  "/data/mnt/internal/MUSIC/other/3.flac",
+			want: &Content{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.Resolve(tt.uri)
+
+			var ambiguous *ErrAmbiguous
+			if errors.As(err, &ambiguous) {
+				var ids []int32
+				for _, c := range ambiguous.Candidates {
+					ids = append(ids, c.ObjectID)
+				}
+
+				if diff := cmp.Diff(tt.wantAmbiguous, ids); diff != "" {
+					t.Errorf("candidates mismatch (-want +got):\n%s", diff)
+				}
+			} else if err != nil || tt.wantAmbiguous != nil {
+				t.Fatalf("Resolve() error = %v, want ambiguous %v", err, tt.wantAmbiguous)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestDBResolver_Parents(t *testing.T) {
+	DBPath = testDB(t,
+		"INSERT INTO object_body (object_id, parent_id, title) VALUES (1, 0, 'MUSIC'), (2, 1, 'A'), (3, 2, 'album'), "+
+			"(4, 3, 'track'), (5, 1, 'single'), (6, 7, 'loop'), (7, 6, 'loop');",
+	)
+
+	r, err := New()
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	MaxDepth = 3
+	defer func() { MaxDepth = 32 }()
+
+	got, err := r.Parents(4, 5, 6, 1, 99)
+	if err != nil {
+		t.Fatalf("Parents() error = %v", err)
+	}
+
+	want := map[int32][]string{
+		4: {"album", "A", "MUSIC"},
+		5: {"MUSIC"},
+		6: {"loop", "loop", "loop"},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Parents() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestDBResolver_Extended(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema []string
+		want   *Content
+	}{
+		{
+			name: "all columns",
+			schema: []string{
+				"ALTER TABLE object_body ADD COLUMN album_artist_id INTEGER;",
+				"ALTER TABLE object_body ADD COLUMN disc_no INTEGER;",
+				"ALTER TABLE object_body ADD COLUMN genre_id INTEGER;",
+				"ALTER TABLE object_body ADD COLUMN release_date TEXT;",
+				"ALTER TABLE object_body ADD COLUMN composer_id INTEGER;",
+				"CREATE TABLE album_artists (id INTEGER PRIMARY KEY, value TEXT);",
+				"CREATE TABLE genres (id INTEGER PRIMARY KEY, value TEXT);",
+				"CREATE TABLE composers (id INTEGER PRIMARY KEY, value TEXT);",
+				"INSERT INTO album_artists VALUES (1, 'various');",
+				"INSERT INTO genres VALUES (1, 'jazz ');",
+				"INSERT INTO composers VALUES (1, 'composer');",
+				"INSERT INTO object_body VALUES (2, 1, 1, 1, 'track', '3', '3.flac', 1, 2, 1, '2004-05-17', 1);",
+			},
+			want: &Content{Artist: "artist", Album: "album", Track: "track", TrackNumber: "3", AlbumArtist: "various",
+				DiscNumber: "2", Genre: "jazz", Year: 2004, Composer: "composer"},
+		},
+		{
+			name: "missing value is empty",
+			schema: []string{
+				"ALTER TABLE object_body ADD COLUMN genre_id INTEGER;",
+				"ALTER TABLE object_body ADD COLUMN release_date TEXT;",
+				"CREATE TABLE genres (id INTEGER PRIMARY KEY, value TEXT);",
+				"INSERT INTO object_body VALUES (2, 1, 1, 1, 'track', '3', '3.flac', 5, NULL);",
+			},
+			want: &Content{Artist: "artist", Album: "album", Track: "track", TrackNumber: "3"},
+		},
+		{
+			name: "columns without lookup table are skipped",
+			schema: []string{
+				"ALTER TABLE object_body ADD COLUMN genre_id INTEGER;",
+				"ALTER TABLE object_body ADD COLUMN disc_no TEXT;",
+				"INSERT INTO object_body VALUES (2, 1, 1, 1, 'track', '3', '3.flac', 1, '1');",
+			},
+			want: &Content{Artist: "artist", Album: "album", Track: "track", TrackNumber: "3", DiscNumber: "1"},
+		},
+		{
+			name:   "no extended columns",
+			schema: []string{"INSERT INTO object_body VALUES (2, 1, 1, 1, 'track', '3', '3.flac');"},
+			want:   &Content{Artist: "artist", Album: "album", Track: "track", TrackNumber: "3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DBPath = testDB(t, append([]string{
+				"INSERT INTO artists VALUES (1, 'artist');",
+				"INSERT INTO albums VALUES (1, 'album');",
+				"INSERT INTO object_body (object_id, parent_id, artist_id, album_id, title) VALUES (1, 0, 1, 1, 'album');",
+			}, tt.schema...)...)
+
+			r, err := New()
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			got, err := r.Resolve("/data/mnt/internal/MUSIC/album/3.flac")
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if diff := cmp.Diff(tt.want, got, cmpopts.EquateEmpty()); diff != "" {
+				t.Errorf("Resolve() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestContent_String(t *testing.T) {
+	tests := []struct {
+		name    string
+		content Content
+		want    string
+	}{
+		{
+			name:    "listened",
+			content: Content{Artist: "artist", Album: "album", Track: "track", TrackNumber: "3", Duration: 215, Rating: true, StartedAt: 1700000000},
+			want:    "artist\talbum\ttrack\t3\t215\tL\t1700000000\t",
+		},
+		{
+			name: "musicbrainz id",
+			content: Content{Artist: "artist", Track: "tr\tack", Duration: 215, StartedAt: 1700000000,
+				MusicBrainzTID: "f5ba2bb6-1dd0-4b0c-8b1b-3d6c1a8f1a7e"},
+			want: "artist\t\ttrack\t\t215\tS\t1700000000\tf5ba2bb6-1dd0-4b0c-8b1b-3d6c1a8f1a7e",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.content.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYear(t *testing.T) {
+	tests := []struct {
+		date string
+		want int
+	}{
+		{date: "2004", want: 2004},
+		{date: "2004-05-17", want: 2004},
+		{date: "20040517", want: 2004},
+		{date: "May 2004", want: 0},
+		{date: "", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			if got := Year(tt.date); got != tt.want {
+				t.Errorf("Year() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
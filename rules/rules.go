@@ -0,0 +1,158 @@
+package rules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"scrobbler/device"
+	"scrobbler/resolver"
+	"strings"
+)
+
+type ContentType string
+
+const (
+	ContentUnknown   ContentType = ""
+	ContentMusic     ContentType = "music"
+	ContentPodcast   ContentType = "podcast"
+	ContentAudiobook ContentType = "audiobook"
+	ContentLanguage  ContentType = "language"
+)
+
+// TypeFolders maps storage folders to content type, MediaStore files content by these folders
+//
+// Media database has no known content type column, so type is taken from the folder under storage root.
+var TypeFolders = map[string]ContentType{
+	"MUSIC":      ContentMusic,
+	"PODCASTS":   ContentPodcast,
+	"AUDIBLE":    ContentAudiobook,
+	"AUDIOBOOKS": ContentAudiobook,
+	"LANGUAGE":   ContentLanguage,
+}
+
+// TypeOf returns content type by the folder under storage root, internal or SD card
+func TypeOf(uri string) ContentType {
+	dir, _, ok := strings.Cut(device.Relative(uri), "/")
+	if !ok {
+		return ContentUnknown
+	}
+
+	return TypeFolders[strings.ToUpper(dir)]
+}
+
+type Action string
+
+const (
+	Include Action = "include"
+	Exclude Action = "exclude"
+)
+
+// Rule matches content by all non-empty fields
+type Rule struct {
+	Action Action      `json:"action"`
+	Path   string      `json:"path,omitempty"` // uri prefix or glob
+	Type   ContentType `json:"type,omitempty"`
+	Artist string      `json:"artist,omitempty"` // case-insensitive
+	Genre  string      `json:"genre,omitempty"`  // case-insensitive, genre is read from file tags
+}
+
+func (r Rule) String() string {
+	var res []string
+	if r.Path != "" {
+		res = append(res, "path="+r.Path)
+	}
+
+	if r.Type != ContentUnknown {
+		res = append(res, "type="+string(r.Type))
+	}
+
+	if r.Artist != "" {
+		res = append(res, "artist="+r.Artist)
+	}
+
+	if r.Genre != "" {
+		res = append(res, "genre="+r.Genre)
+	}
+
+	return string(r.Action) + " " + strings.Join(res, " ")
+}
+
+func (r Rule) Match(uri string, c resolver.Content) bool {
+	if !device.MatchPath(r.Path, uri) {
+		return false
+	}
+
+	if r.Type != ContentUnknown && r.Type != TypeOf(uri) {
+		return false
+	}
+
+	if r.Artist != "" && !strings.EqualFold(r.Artist, c.Artist) {
+		return false
+	}
+
+	if r.Genre != "" && !strings.EqualFold(r.Genre, c.Genre) {
+		return false
+	}
+
+	return true
+}
+
+// Default rules keep non-music content out of scrobbler log
+var Default = []Rule{
+	{Action: Exclude, Type: ContentPodcast},
+	{Action: Exclude, Type: ContentAudiobook},
+	{Action: Exclude, Type: ContentLanguage},
+}
+
+// Rules are evaluated in order, first matching rule wins; content not matched by any rule is included
+type Rules struct {
+	Rules []Rule `json:"rules"`
+}
+
+func New() *Rules {
+	return &Rules{Rules: append([]Rule{}, Default...)}
+}
+
+// Load reads user rules from file, default rules are evaluated after them
+func Load(filename string) (*Rules, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return New(), nil
+		}
+
+		return New(), fmt.Errorf("cannot read rules: %w", err)
+	}
+
+	r := &Rules{}
+	if err = json.Unmarshal(data, r); err != nil {
+		return New(), fmt.Errorf("cannot unmarshal rules: %w", err)
+	}
+
+	for i, rule := range r.Rules {
+		if rule.Action != Include && rule.Action != Exclude {
+			return New(), fmt.Errorf("invalid action in rule %s", rule)
+		}
+
+		if rule.Path == "" && rule.Type == ContentUnknown && rule.Artist == "" && rule.Genre == "" {
+			return New(), fmt.Errorf("rule %d matches everything, set path, type, artist or genre", i+1)
+		}
+	}
+
+	r.Rules = append(r.Rules, Default...)
+
+	return r, nil
+}
+
+// Excluded returns rule which excludes content from scrobbling
+func (r *Rules) Excluded(uri string, c resolver.Content) (Rule, bool) {
+	for _, rule := range r.Rules {
+		if rule.Match(uri, c) {
+			return rule, rule.Action == Exclude
+		}
+	}
+
+	return Rule{}, false
+}
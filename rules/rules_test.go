@@ -0,0 +1,166 @@
+package rules
+
+import (
+	"os"
+	"path/filepath"
+	"scrobbler/resolver"
+	"testing"
+)
+
+func TestTypeOf(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want ContentType
+	}{
+		{uri: "/data/mnt/internal/MUSIC/artist/album/1.flac", want: ContentMusic},
+		{uri: "/data/mnt/internal/PODCASTS/show/1.mp3", want: ContentPodcast},
+		{uri: "/data/mnt/internal/Audible/book.aax", want: ContentAudiobook},
+		{uri: "/data/mnt/internal/LANGUAGE/lesson.mp3", want: ContentLanguage},
+		{uri: "/data/mnt/internal/MUSIC/PODCASTS/1.mp3", want: ContentMusic},
+		{uri: "/data/mnt/internal/other/PODCASTS.mp3", want: ContentUnknown},
+		{uri: "/data/mnt/internal/other/PODCASTS/1.mp3", want: ContentUnknown},
+		{uri: "/contents_ext/PODCASTS/show/1.mp3", want: ContentPodcast},
+		{uri: "/data/mnt/internal/1.mp3", want: ContentUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			if got := TypeOf(tt.uri); got != tt.want {
+				t.Errorf("TypeOf() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRules_Excluded(t *testing.T) {
+	music := "/data/mnt/internal/MUSIC/artist/album/1.flac"
+	podcast := "/data/mnt/internal/PODCASTS/show/1.mp3"
+
+	tests := []struct {
+		name    string
+		rules   []Rule
+		uri     string
+		content resolver.Content
+		want    bool
+	}{
+		{
+			name: "music",
+			uri:  music,
+			want: false,
+		},
+		{
+			name: "podcast",
+			uri:  podcast,
+			want: true,
+		},
+		{
+			name:  "podcast included by user rule",
+			rules: []Rule{{Action: Include, Path: "/data/mnt/internal/PODCASTS/show/"}},
+			uri:   podcast,
+			want:  false,
+		},
+		{
+			name:  "glob",
+			rules: []Rule{{Action: Exclude, Path: "/data/mnt/internal/MUSIC/*/album/*"}},
+			uri:   music,
+			want:  true,
+		},
+		{
+			name:  "path prefix ends at directory",
+			rules: []Rule{{Action: Exclude, Path: "/data/mnt/internal/MUSIC/art"}},
+			uri:   music,
+			want:  false,
+		},
+		{
+			name:  "path directory",
+			rules: []Rule{{Action: Exclude, Path: "/data/mnt/internal/MUSIC/artist"}},
+			uri:   music,
+			want:  true,
+		},
+		{
+			name:    "artist",
+			rules:   []Rule{{Action: Exclude, Artist: "ARTIST"}},
+			uri:     music,
+			content: resolver.Content{Artist: "Artist"},
+			want:    true,
+		},
+		{
+			name:  "genre is not known",
+			rules: []Rule{{Action: Exclude, Genre: "Spoken Word"}},
+			uri:   music,
+			want:  false,
+		},
+		{
+			name:    "all fields must match",
+			rules:   []Rule{{Action: Exclude, Type: ContentMusic, Genre: "Spoken Word"}},
+			uri:     music,
+			content: resolver.Content{Genre: "Rock"},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Rules{Rules: append(tt.rules, Default...)}
+			if _, got := r.Excluded(tt.uri, tt.content); got != tt.want {
+				t.Errorf("Excluded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name      string
+		data      string
+		wantErr   bool
+		wantRules int
+	}{
+		{
+			name:      "no file",
+			wantRules: len(Default),
+		},
+		{
+			name:      "user rules",
+			data:      `{"rules": [{"action": "exclude", "artist": "White Noise"}]}`,
+			wantRules: len(Default) + 1,
+		},
+		{
+			name:      "invalid action",
+			data:      `{"rules": [{"action": "skip", "artist": "White Noise"}]}`,
+			wantErr:   true,
+			wantRules: len(Default),
+		},
+		{
+			name:      "rule without matcher",
+			data:      `{"rules": [{"action": "exclude"}]}`,
+			wantErr:   true,
+			wantRules: len(Default),
+		},
+		{
+			name:      "invalid json",
+			data:      `{"rules": [`,
+			wantErr:   true,
+			wantRules: len(Default),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, tt.name+".json")
+			if tt.data != "" {
+				if err := os.WriteFile(filename, []byte(tt.data), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := Load(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if len(got.Rules) != tt.wantRules {
+				t.Errorf("Load() rules = %d, want %d", len(got.Rules), tt.wantRules)
+			}
+		})
+	}
+}
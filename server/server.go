@@ -9,12 +9,15 @@ import (
 	"os"
 	"os/signal"
 	"scrobbler/audioplayer"
+	"scrobbler/history"
+	"scrobbler/playerevents"
 	"syscall"
 )
 
 type Server struct {
 	socketAddr string
 	player     *audioplayer.AudioPlayer
+	history    *history.History
 }
 
 func New(addr string) *Server {
@@ -25,6 +28,8 @@ var CMDListBegin = []byte("noidle\ncommand_list_begin\n")
 var CMDStatus = []byte("status\n")
 var CMDCurrentSong = []byte("currentsong\n")
 var CMDListEnd = []byte("command_list_end\nidle\n")
+var CMDHistory = []byte("history\n")
+var CMDExplain = []byte("explain\n")
 
 var CMDStatusBatch = append(CMDStatus, CMDCurrentSong...)
 var CMDStatusBatchAll = bytes.Join([][]byte{CMDListBegin, CMDStatus, CMDCurrentSong, CMDListEnd}, []byte(""))
@@ -36,6 +41,56 @@ func (s *Server) WithAudioPlayer(player *audioplayer.AudioPlayer) *Server {
 	return s
 }
 
+func (s *Server) WithHistory(h *history.History) *Server {
+	s.history = h
+	return s
+}
+
+// historyReply lists plays grouped by sessions, oldest first
+func (s *Server) historyReply() []byte {
+	if s.history == nil {
+		return nil
+	}
+
+	b := &bytes.Buffer{}
+	for i, session := range s.history.Sessions() {
+		fmt.Fprintf(b, "session: %d\nstart: %d\nend: %d\n", i, session.Start.Unix(), session.End.Unix())
+		for _, p := range session.Plays {
+			fmt.Fprintf(b,
+				"file: %s\n"+
+					"Artist: %s\n"+
+					"Album: %s\n"+
+					"Title: %s\n"+
+					"start: %d\n"+
+					"end: %d\n"+
+					"elapsed: %d\n"+
+					"outcome: %s\n"+
+					"reason: %s\n",
+				p.URI,
+				p.Content.Artist,
+				p.Content.Album,
+				p.Content.Track,
+				p.Start.Unix(),
+				p.End.Unix(),
+				p.ListenedFor,
+				p.Outcome,
+				p.Reason,
+			)
+		}
+	}
+
+	return b.Bytes()
+}
+
+// flag formats bool the way MPD does
+func flag(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
 var StateByID = map[int]string{
 	audioplayer.StatePause:     "pause",
 	audioplayer.StateExecuting: "play",
@@ -85,15 +140,19 @@ func (s *Server) Start() {
 				}
 
 				if bytes.Equal(CMDStatusBatchAll, buf[:n]) {
+					snap := s.player.Snapshot()
 					state := ""
 					var ok bool
-					if state, ok = StateByID[s.player.State]; !ok {
+					if state, ok = StateByID[snap.State]; !ok {
 						state = "stop"
 					}
 
 					res := fmt.Sprintf(
 						"OK\n"+
 							"volume: %d\n"+
+							"repeat: %d\n"+
+							"random: %d\n"+
+							"single: %d\n"+
 							"state: %s\n"+
 							"elapsed: %d\n"+
 							"bitrate: %d\n"+
@@ -107,20 +166,33 @@ func (s *Server) Start() {
 							//"Date: %s\n"+
 							"OK\n",
 						50,
+						flag(snap.Mode.Repeat != playerevents.RepeatOff),
+						flag(snap.Mode.Shuffle),
+						flag(snap.Mode.Repeat == playerevents.RepeatOne),
 						state,
-						s.player.CurrentTrack.PlayingFor,
-						s.player.CurrentContent.Bitrate/1000,
-						s.player.CurrentContent.Duration,
-						s.player.CurrentTrack.ContentURI,
-						s.player.CurrentContent.SampleRate, s.player.CurrentContent.BitDepth, s.player.CurrentContent.Channels,
-						s.player.CurrentContent.Artist,
-						s.player.CurrentContent.Album,
-						s.player.CurrentContent.Track,
-						s.player.CurrentContent.TrackNumber,
+						snap.Elapsed,
+						snap.Content.Bitrate/1000,
+						snap.Content.Duration,
+						snap.URI,
+						snap.Content.SampleRate, snap.Content.BitDepth, snap.Content.Channels,
+						snap.Content.Artist,
+						snap.Content.Album,
+						snap.Content.Track,
+						snap.Content.TrackNumber,
 					)
 					conn.Write([]byte(res))
 				}
 
+				if bytes.Equal(CMDHistory, buf[:n]) {
+					conn.Write(s.historyReply())
+				}
+
+				if bytes.Equal(CMDExplain, buf[:n]) {
+					for _, t := range s.player.Explain() {
+						conn.Write([]byte(t.String()))
+					}
+				}
+
 				_, err = conn.Write(ReplyOK)
 				if err != nil {
 					slog.Error("cannot write to socket", "err", err.Error())
@@ -0,0 +1,41 @@
+package tags
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+)
+
+// readDSF reads fmt chunk and ID3v2 tag pointed by DSD chunk
+func readDSF(r io.ReaderAt, t *Tags) error {
+	b, err := readAt(r, 0, 28+52)
+	if err != nil {
+		return fmt.Errorf("cannot read dsf header: %w", err)
+	}
+
+	if string(b[28:32]) != "fmt " {
+		return fmt.Errorf("no dsf fmt chunk")
+	}
+
+	metadata := int64(binary.LittleEndian.Uint64(b[20:28]))
+	format := b[28:]
+
+	t.Channels = int(le32(format[24:]))
+	t.SampleRate = int(le32(format[28:]))
+	t.BitDepth = int(le32(format[32:]))
+	samples := binary.LittleEndian.Uint64(format[36:])
+	if t.SampleRate > 0 {
+		t.Duration = time.Duration(samples) * time.Second / time.Duration(t.SampleRate)
+	}
+
+	if metadata == 0 {
+		return nil
+	}
+
+	if _, err = readID3(r, metadata, t); err != nil {
+		return fmt.Errorf("cannot read dsf metadata: %w", err)
+	}
+
+	return nil
+}
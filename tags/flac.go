@@ -0,0 +1,132 @@
+package tags
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+)
+
+// readFLAC reads STREAMINFO and VORBIS_COMMENT metadata blocks
+func readFLAC(r io.ReaderAt, off int64, t *Tags) error {
+	off += 4 // fLaC
+	for {
+		header, err := readAt(r, off, 4)
+		if err != nil {
+			return fmt.Errorf("cannot read flac block header: %w", err)
+		}
+
+		last := header[0]&0x80 != 0
+		kind := header[0] & 0x7F
+		length := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		off += 4
+
+		switch kind {
+		case flacStreamInfo:
+			b, err := readAt(r, off, length)
+			if err != nil || length < 18 {
+				return fmt.Errorf("cannot read flac stream info: %w", err)
+			}
+
+			t.SampleRate = int(b[10])<<12 | int(b[11])<<4 | int(b[12])>>4
+			t.Channels = int(b[12]>>1&0x07) + 1
+			t.BitDepth = int(b[12]&0x01)<<4 | int(b[13])>>4 + 1
+			samples := uint64(b[13]&0x0F)<<32 | uint64(be32(b[14:18]))
+			if t.SampleRate > 0 {
+				t.Duration = time.Duration(samples) * time.Second / time.Duration(t.SampleRate)
+			}
+		case flacVorbisComment:
+			b, err := readAt(r, off, length)
+			if err != nil {
+				return fmt.Errorf("cannot read vorbis comment: %w", err)
+			}
+
+			vorbisComment(b, t)
+		}
+
+		if last {
+			return nil
+		}
+
+		off += int64(length)
+	}
+}
+
+// vorbisComment parses little-endian vendor string and list of KEY=value comments
+func vorbisComment(b []byte, t *Tags) {
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+
+		// compared before conversion, int is 32-bit on device
+		n := int64(le32(b))
+		if n > int64(len(b)-4) {
+			return "", false
+		}
+
+		res := string(b[4 : 4+n])
+		b = b[4+n:]
+
+		return res, true
+	}
+
+	if _, ok := next(); !ok { // vendor
+		return
+	}
+
+	if len(b) < 4 {
+		return
+	}
+	count := le32(b)
+	b = b[4:]
+
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+
+		key, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+
+		switch strings.ToUpper(key) {
+		case "ARTIST":
+			t.Artist = first(t.Artist, value)
+		case "ALBUM":
+			t.Album = first(t.Album, value)
+		case "TITLE":
+			t.Title = first(t.Title, value)
+		case "TRACKNUMBER":
+			t.TrackNumber = first(t.TrackNumber, value)
+		case "ALBUMARTIST", "ALBUM ARTIST":
+			t.AlbumArtist = first(t.AlbumArtist, value)
+		case "DISCNUMBER":
+			t.DiscNumber = first(t.DiscNumber, value)
+		case "GENRE":
+			t.Genre = first(t.Genre, value)
+		case "DATE", "YEAR":
+			t.Date = first(t.Date, value)
+		case "COMPOSER":
+			t.Composer = first(t.Composer, value)
+		case "MUSICBRAINZ_TRACKID":
+			t.MusicBrainzTID = first(t.MusicBrainzTID, value)
+		}
+	}
+}
+
+// first keeps the first value of repeated field
+func first(current, value string) string {
+	if current != "" {
+		return current
+	}
+
+	return value
+}
@@ -0,0 +1,47 @@
+package tags
+
+import (
+	"strconv"
+	"strings"
+)
+
+// id3v1Genres are referenced by index from ID3v2 TCON "(17)" or "17" and MP4 gnre
+var id3v1Genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop", "Jazz", "Metal",
+	"New Age", "Oldies", "Other", "Pop", "R&B", "Rap", "Reggae", "Rock", "Techno", "Industrial",
+	"Alternative", "Ska", "Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk",
+	"Fusion", "Trance", "Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic",
+	"Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream", "Southern Rock", "Comedy", "Cult", "Gangsta",
+	"Top 40", "Christian Rap", "Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes",
+	"Trailer", "Lo-Fi", "Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// genre resolves ID3v1 genre references: "(17)" and "17" are "Rock", "(17)Classic" is "Classic"
+func genre(s string) string {
+	s = strings.TrimSpace(s)
+	ref := s
+	if strings.HasPrefix(s, "(") {
+		end := strings.Index(s, ")")
+		if end < 0 {
+			return s
+		}
+
+		if refined := strings.TrimSpace(s[end+1:]); refined != "" {
+			return refined
+		}
+
+		ref = s[1:end]
+	}
+
+	i, err := strconv.Atoi(ref)
+	if err != nil {
+		return s
+	}
+
+	if i < 0 || i >= len(id3v1Genres) {
+		return ""
+	}
+
+	return id3v1Genres[i]
+}
@@ -0,0 +1,190 @@
+package tags
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+const (
+	id3HeaderSize     = 10
+	id3FlagExtended   = 0x40
+	id3FlagFooter     = 0x10
+	id3MaxTextFrame   = 64 * 1024
+	id3MusicBrainz    = "http://musicbrainz.org"
+	id3EncodingLatin1 = 0
+	id3EncodingUTF16  = 1
+	id3EncodingUTF16B = 2
+	id3EncodingUTF8   = 3
+)
+
+// syncsafe decodes 28-bit integer stored in 7 bits of each byte
+func syncsafe(b []byte) int64 {
+	return int64(b[0]&0x7F)<<21 | int64(b[1]&0x7F)<<14 | int64(b[2]&0x7F)<<7 | int64(b[3]&0x7F)
+}
+
+// readID3 reads ID3v2.3 or ID3v2.4 tag at off, returns full size of the tag
+//
+// Only text frames are read, everything else (pictures, lyrics) is skipped.
+func readID3(r io.ReaderAt, off int64, t *Tags) (int64, error) {
+	header, err := readAt(r, off, id3HeaderSize)
+	if err != nil {
+		return 0, fmt.Errorf("cannot read id3 header: %w", err)
+	}
+
+	if string(header[0:3]) != "ID3" {
+		return 0, fmt.Errorf("no id3 header")
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	total := id3HeaderSize + size
+	if flags&id3FlagFooter != 0 {
+		total += id3HeaderSize
+	}
+
+	if version != 3 && version != 4 {
+		return total, fmt.Errorf("id3v2.%d: %w", version, ErrUnsupported)
+	}
+
+	pos := off + id3HeaderSize
+	end := pos + size
+
+	if flags&id3FlagExtended != 0 {
+		ext, err := readAt(r, pos, 4)
+		if err != nil {
+			return total, fmt.Errorf("cannot read id3 extended header: %w", err)
+		}
+
+		if version == 4 {
+			pos += syncsafe(ext) // includes size itself
+		} else {
+			pos += 4 + int64(be32(ext))
+		}
+	}
+
+	for pos+id3HeaderSize <= end {
+		fh, err := readAt(r, pos, id3HeaderSize)
+		if err != nil {
+			return total, fmt.Errorf("cannot read id3 frame header: %w", err)
+		}
+
+		if fh[0] == 0 { // padding
+			break
+		}
+
+		id := string(fh[0:4])
+		frameSize := int64(be32(fh[4:8]))
+		if version == 4 {
+			frameSize = syncsafe(fh[4:8])
+		}
+
+		pos += id3HeaderSize
+		if frameSize <= 0 || pos+frameSize > end {
+			break
+		}
+
+		if strings.HasPrefix(id, "T") && frameSize <= id3MaxTextFrame {
+			b, err := readAt(r, pos, int(frameSize))
+			if err != nil {
+				return total, fmt.Errorf("cannot read id3 frame %s: %w", id, err)
+			}
+
+			id3Text(id, decodeText(b), t)
+		}
+
+		if id == "UFID" && frameSize <= id3MaxTextFrame {
+			b, err := readAt(r, pos, int(frameSize))
+			if err != nil {
+				return total, fmt.Errorf("cannot read id3 frame %s: %w", id, err)
+			}
+
+			// owner identifier, null, identifier
+			if owner, value, ok := bytes.Cut(b, []byte{0}); ok && string(owner) == id3MusicBrainz {
+				t.MusicBrainzTID = first(t.MusicBrainzTID, string(value))
+			}
+		}
+
+		pos += frameSize
+	}
+
+	return total, nil
+}
+
+func id3Text(id string, value string, t *Tags) {
+	switch id {
+	case "TPE1":
+		t.Artist = first(t.Artist, value)
+	case "TALB":
+		t.Album = first(t.Album, value)
+	case "TIT2":
+		t.Title = first(t.Title, value)
+	case "TRCK":
+		t.TrackNumber = first(t.TrackNumber, value)
+	case "TPE2":
+		t.AlbumArtist = first(t.AlbumArtist, value)
+	case "TPOS":
+		t.DiscNumber = first(t.DiscNumber, value)
+	case "TCON":
+		t.Genre = first(t.Genre, value)
+	case "TYER", "TDRC":
+		t.Date = first(t.Date, value)
+	case "TCOM":
+		t.Composer = first(t.Composer, value)
+	case "TLEN":
+		if ms, err := strconv.Atoi(value); err == nil && t.Duration == 0 {
+			t.Duration = time.Duration(ms) * time.Millisecond
+		}
+	}
+}
+
+// decodeText decodes text frame, only the first of multiple null-separated values is returned
+func decodeText(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+
+	encoding, b := b[0], b[1:]
+
+	var res string
+	switch encoding {
+	case id3EncodingLatin1:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		res = string(runes)
+	case id3EncodingUTF16, id3EncodingUTF16B:
+		bigEndian := encoding == id3EncodingUTF16B
+		if len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE {
+			bigEndian, b = false, b[2:]
+		} else if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+			bigEndian, b = true, b[2:]
+		}
+
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			if bigEndian {
+				units[i] = uint16(b[2*i])<<8 | uint16(b[2*i+1])
+			} else {
+				units[i] = uint16(b[2*i+1])<<8 | uint16(b[2*i])
+			}
+		}
+		res = string(utf16.Decode(units))
+	case id3EncodingUTF8:
+		res = string(b)
+	default:
+		return ""
+	}
+
+	if i := strings.IndexByte(res, 0); i >= 0 {
+		res = res[:i]
+	}
+
+	return strings.TrimSpace(res)
+}
@@ -0,0 +1,116 @@
+package tags
+
+import (
+	"io"
+	"time"
+)
+
+// mp3ScanLimit is how far first frame is searched after the tag
+const mp3ScanLimit = 64 * 1024
+
+var (
+	mp3Bitrates1 = [16]int{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 0}
+	mp3Bitrates2 = [16]int{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, 0}
+	mp3Rates     = [3]int{44100, 48000, 32000}
+)
+
+const (
+	mpeg25 = 0
+	mpeg2  = 2
+	mpeg1  = 3
+	layer3 = 1
+	mono   = 3
+)
+
+// readMP3 reads mp3 without ID3v2 tag, only duration is known
+func readMP3(r io.ReaderAt, off int64, size int64, t *Tags) error {
+	t.Duration, t.SampleRate, t.Channels = mp3Duration(r, off, size)
+	return nil
+}
+
+// mp3Duration finds first MPEG layer III frame after off, duration is taken from Xing/Info or VBRI header
+// if there is one, otherwise stream is considered to be CBR
+func mp3Duration(r io.ReaderAt, off int64, size int64) (time.Duration, int, int) {
+	n := int64(mp3ScanLimit)
+	if off+n > size {
+		n = size - off
+	}
+
+	if n < 4 {
+		return 0, 0, 0
+	}
+
+	b, err := readAt(r, off, int(n))
+	if err != nil {
+		return 0, 0, 0
+	}
+
+	for i := 0; i+4 <= len(b); i++ {
+		if b[i] != 0xFF || b[i+1]&0xE0 != 0xE0 {
+			continue
+		}
+
+		version := int(b[i+1]>>3) & 0x03
+		layer := int(b[i+1]>>1) & 0x03
+		bitrateIndex := int(b[i+2] >> 4)
+		rateIndex := int(b[i+2]>>2) & 0x03
+		channelMode := int(b[i+3] >> 6)
+
+		if version == 1 || layer != layer3 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+			continue
+		}
+
+		rate := mp3Rates[rateIndex]
+		bitrate := mp3Bitrates1[bitrateIndex]
+		samplesPerFrame := 1152
+		sideInfo := 32
+		if channelMode == mono {
+			sideInfo = 17
+		}
+
+		if version != mpeg1 {
+			rate /= 2
+			if version == mpeg25 {
+				rate /= 2
+			}
+			bitrate = mp3Bitrates2[bitrateIndex]
+			samplesPerFrame = 576
+			sideInfo = 17
+			if channelMode == mono {
+				sideInfo = 9
+			}
+		}
+
+		channels := 2
+		if channelMode == mono {
+			channels = 1
+		}
+
+		frame := b[i:]
+		if frames := vbrFrames(frame, 4+sideInfo); frames > 0 {
+			return time.Duration(frames) * time.Duration(samplesPerFrame) * time.Second / time.Duration(rate), rate, channels
+		}
+
+		audio := size - off - int64(i)
+		return time.Duration(audio*8) * time.Second / time.Duration(bitrate*1000), rate, channels
+	}
+
+	return 0, 0, 0
+}
+
+// vbrFrames returns frame count from Xing/Info header after side info or VBRI header at fixed offset
+func vbrFrames(frame []byte, xing int) int {
+	if len(frame) >= xing+12 {
+		id := string(frame[xing : xing+4])
+		if (id == "Xing" || id == "Info") && be32(frame[xing+4:])&0x01 != 0 {
+			return int(be32(frame[xing+8:]))
+		}
+	}
+
+	const vbri = 4 + 32
+	if len(frame) >= vbri+18 && string(frame[vbri:vbri+4]) == "VBRI" {
+		return int(be32(frame[vbri+14:]))
+	}
+
+	return 0
+}
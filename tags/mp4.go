@@ -0,0 +1,185 @@
+package tags
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// mp4MaxMoov limits size of moov box read into memory
+const mp4MaxMoov = 64 * 1024 * 1024
+
+// readMP4 reads duration from mvhd and tags from iTunes-style moov/udta/meta/ilst
+func readMP4(r io.ReaderAt, size int64, t *Tags) error {
+	var off int64
+	for off+8 <= size {
+		header, err := readUpTo(r, off, 16)
+		if err != nil {
+			return fmt.Errorf("cannot read mp4 box header: %w", err)
+		}
+
+		if len(header) < 8 {
+			break
+		}
+
+		boxSize := int64(be32(header))
+		headerSize := int64(8)
+		switch boxSize {
+		case 0:
+			boxSize = size - off
+		case 1:
+			if len(header) < 16 {
+				return fmt.Errorf("truncated mp4 box header")
+			}
+
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+
+		if boxSize < headerSize || boxSize > size-off {
+			return fmt.Errorf("invalid mp4 box size %d", boxSize)
+		}
+
+		if string(header[4:8]) == "moov" {
+			if boxSize > mp4MaxMoov {
+				return fmt.Errorf("mp4 moov box is too big: %d", boxSize)
+			}
+
+			moov, err := readAt(r, off+headerSize, int(boxSize-headerSize))
+			if err != nil {
+				return fmt.Errorf("cannot read mp4 moov box: %w", err)
+			}
+
+			mp4Moov(moov, t)
+			return nil
+		}
+
+		off += boxSize
+	}
+
+	return fmt.Errorf("no mp4 moov box")
+}
+
+// mp4Boxes calls f for every box in b
+func mp4Boxes(b []byte, f func(kind string, body []byte)) {
+	for len(b) >= 8 {
+		size := uint64(be32(b))
+		headerSize := uint64(8)
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return
+			}
+			size = binary.BigEndian.Uint64(b[8:16])
+			headerSize = 16
+		}
+
+		if size < headerSize || size > uint64(len(b)) {
+			return
+		}
+
+		f(string(b[4:8]), b[headerSize:size])
+		b = b[size:]
+	}
+}
+
+func mp4Moov(moov []byte, t *Tags) {
+	mp4Boxes(moov, func(kind string, body []byte) {
+		switch kind {
+		case "mvhd":
+			mp4Duration(body, t)
+		case "udta":
+			mp4Boxes(body, func(kind string, body []byte) {
+				if kind != "meta" {
+					return
+				}
+
+				// meta is a full box with version and flags, except QuickTime files
+				if len(body) >= 8 && string(body[4:8]) != "hdlr" {
+					body = body[4:]
+				}
+
+				mp4Boxes(body, func(kind string, body []byte) {
+					if kind == "ilst" {
+						mp4Boxes(body, func(kind string, body []byte) {
+							mp4Item(kind, body, t)
+						})
+					}
+				})
+			})
+		}
+	})
+}
+
+func mp4Duration(mvhd []byte, t *Tags) {
+	var timescale, duration uint64
+	switch {
+	case len(mvhd) >= 32 && mvhd[0] == 1:
+		timescale = uint64(be32(mvhd[20:]))
+		duration = binary.BigEndian.Uint64(mvhd[24:])
+	case len(mvhd) >= 20:
+		timescale = uint64(be32(mvhd[12:]))
+		duration = uint64(be32(mvhd[16:]))
+	}
+
+	if timescale > 0 {
+		t.Duration = time.Duration(duration) * time.Second / time.Duration(timescale)
+	}
+}
+
+const mp4MusicBrainzTID = "MusicBrainz Track Id"
+
+// mp4Item reads ilst item, value is in data box after type and locale
+//
+// Freeform "----" items are named by name box, e.g. ----:com.apple.iTunes:MusicBrainz Track Id
+func mp4Item(kind string, item []byte, t *Tags) {
+	name := ""
+	mp4Boxes(item, func(box string, body []byte) {
+		if box == "name" && len(body) >= 4 {
+			name = string(body[4:]) // full box
+		}
+
+		if box != "data" || len(body) < 8 {
+			return
+		}
+
+		value := body[8:]
+		switch kind {
+		case "\xa9ART":
+			t.Artist = first(t.Artist, string(value))
+		case "\xa9alb":
+			t.Album = first(t.Album, string(value))
+		case "\xa9nam":
+			t.Title = first(t.Title, string(value))
+		case "trkn":
+			if len(value) >= 4 {
+				t.TrackNumber = first(t.TrackNumber, strconv.Itoa(int(binary.BigEndian.Uint16(value[2:4]))))
+			}
+		case "aART":
+			t.AlbumArtist = first(t.AlbumArtist, string(value))
+		case "disk":
+			if len(value) >= 4 {
+				t.DiscNumber = first(t.DiscNumber, strconv.Itoa(int(binary.BigEndian.Uint16(value[2:4]))))
+			}
+		case "\xa9gen":
+			t.Genre = first(t.Genre, string(value))
+		case "gnre":
+			// ID3v1 genre index + 1
+			if len(value) >= 2 {
+				t.Genre = first(t.Genre, fmt.Sprintf("(%d)", int(binary.BigEndian.Uint16(value))-1))
+			}
+		case "\xa9day":
+			t.Date = first(t.Date, string(value))
+		case "\xa9wrt":
+			t.Composer = first(t.Composer, string(value))
+		case "----":
+			if name == mp4MusicBrainzTID {
+				t.MusicBrainzTID = first(t.MusicBrainzTID, string(value))
+			}
+		}
+	})
+}
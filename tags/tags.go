@@ -0,0 +1,142 @@
+// Package tags reads metadata and duration directly from audio files
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+type Tags struct {
+	Artist         string
+	Album          string
+	Title          string
+	TrackNumber    string
+	MusicBrainzTID string // recording id
+	AlbumArtist    string
+	DiscNumber     string
+	Genre          string
+	Date           string // release date or year
+	Composer       string
+	Duration       time.Duration
+	SampleRate     int
+	Channels       int
+	BitDepth       int
+}
+
+var ErrUnsupported = errors.New("unsupported format")
+
+// Read detects file format by its header and reads tags
+func Read(filename string) (*Tags, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open: %w", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("cannot stat: %w", err)
+	}
+
+	t, err := ReadFrom(f, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("cannot read tags from %s: %w", filename, err)
+	}
+
+	return t, nil
+}
+
+func ReadFrom(r io.ReaderAt, size int64) (*Tags, error) {
+	header := make([]byte, 12)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("cannot read header: %w", err)
+	}
+
+	t := &Tags{}
+	var err error
+
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		err = readFLAC(r, 0, t)
+	case bytes.HasPrefix(header, []byte("ID3")):
+		err = readID3Prefixed(r, size, t)
+	case bytes.HasPrefix(header, []byte("DSD ")):
+		err = readDSF(r, t)
+	case bytes.HasPrefix(header, []byte("RIFF")) && string(header[8:12]) == "WAVE":
+		err = readWAV(r, size, t)
+	case string(header[4:8]) == "ftyp":
+		err = readMP4(r, size, t)
+	case header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		err = readMP3(r, 0, size, t)
+	default:
+		return nil, ErrUnsupported
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	t.TrackNumber = trackNumber(t.TrackNumber)
+	t.DiscNumber = trackNumber(t.DiscNumber)
+	t.Genre = genre(t.Genre)
+
+	return t, nil
+}
+
+// readID3Prefixed reads file which starts with ID3v2 tag, either mp3 or flac
+func readID3Prefixed(r io.ReaderAt, size int64, t *Tags) error {
+	tagSize, err := readID3(r, 0, t)
+	if err != nil {
+		return err
+	}
+
+	magic := make([]byte, 4)
+	if _, err = r.ReadAt(magic, tagSize); err != nil {
+		return fmt.Errorf("cannot read header after id3: %w", err)
+	}
+
+	if string(magic) == "fLaC" {
+		return readFLAC(r, tagSize, t)
+	}
+
+	if t.Duration == 0 {
+		t.Duration, t.SampleRate, t.Channels = mp3Duration(r, tagSize, size)
+	}
+
+	return nil
+}
+
+// trackNumber drops total from "3/12", same is used for disc number
+func trackNumber(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(s), "/")
+	return s
+}
+
+func readAt(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := r.ReadAt(b, off); err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// readUpTo reads at most n bytes at off, short read at the end of file is not an error
+func readUpTo(r io.ReaderAt, off int64, n int) ([]byte, error) {
+	b := make([]byte, n)
+	read, err := r.ReadAt(b, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+
+	return b[:read], nil
+}
+
+func le32(b []byte) uint32 { return binary.LittleEndian.Uint32(b) }
+func be32(b []byte) uint32 { return binary.BigEndian.Uint32(b) }
@@ -0,0 +1,350 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func be(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func le(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+func cat(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+func syncsafeBytes(v int) []byte {
+	return []byte{byte(v >> 21 & 0x7F), byte(v >> 14 & 0x7F), byte(v >> 7 & 0x7F), byte(v & 0x7F)}
+}
+
+type frame struct {
+	id    string
+	value []byte // with encoding byte
+}
+
+func latin1(s string) []byte { return append([]byte{id3EncodingLatin1}, s...) }
+
+func utf16LE(s string) []byte {
+	res := []byte{id3EncodingUTF16, 0xFF, 0xFE}
+	for _, u := range utf16.Encode([]rune(s)) {
+		res = append(res, byte(u), byte(u>>8))
+	}
+	return res
+}
+
+// id3 builds ID3v2 tag of given version with padding
+func id3(version byte, frames ...frame) []byte {
+	var body []byte
+	for _, f := range frames {
+		size := be(uint32(len(f.value)))
+		if version == 4 {
+			size = syncsafeBytes(len(f.value))
+		}
+		body = cat(body, []byte(f.id), size, []byte{0, 0}, f.value)
+	}
+	body = append(body, make([]byte, 16)...)
+
+	return cat([]byte("ID3"), []byte{version, 0, 0}, syncsafeBytes(len(body)), body)
+}
+
+const mbid = "f5ba2bb6-1dd0-4b0c-8b1b-3d6c1a8f1a7e"
+
+var ufid = frame{"UFID", []byte(id3MusicBrainz + "\x00" + mbid)}
+
+var extendedFrames = []frame{
+	{"TPE2", latin1("album artist")},
+	{"TPOS", latin1("1/2")},
+	{"TCON", latin1("(17)")},
+	{"TYER", latin1("2004")},
+	{"TCOM", latin1("composer")},
+}
+
+var commonFrames = []frame{
+	{"UFID", []byte("http://example.com\x00other")},
+	{"TPE1", latin1("artist")},
+	{"TALB", latin1("album")},
+	{"APIC", make([]byte, 100)},
+	{"TIT2", latin1("track")},
+	{"TRCK", latin1("3/12")},
+	ufid,
+	extendedFrames[0], extendedFrames[1], extendedFrames[2], extendedFrames[3], extendedFrames[4],
+}
+
+func flac() []byte {
+	info := make([]byte, 34)
+	samples := uint64(44100 * 215)
+	// 44100 Hz, 2 channels, 16 bits
+	info[10], info[11], info[12], info[13] = 0x0A, 0xC4, 0x42, 0xF0|byte(samples>>32)
+	copy(info[14:], be(uint32(samples)))
+
+	var comments []byte
+	list := []string{"ARTIST=artist", "album=album", "TITLE=track", "TRACKNUMBER=3", "ARTIST=second artist",
+		"MUSICBRAINZ_TRACKID=" + mbid, "ALBUMARTIST=album artist", "DISCNUMBER=1", "GENRE=Rock", "DATE=2004",
+		"COMPOSER=composer"}
+	for _, c := range list {
+		comments = cat(comments, le(uint32(len(c))), []byte(c))
+	}
+	vorbis := cat(le(6), []byte("vendor"), le(uint32(len(list))), comments)
+
+	block := func(kind byte, last bool, body []byte) []byte {
+		if last {
+			kind |= 0x80
+		}
+		return cat([]byte{kind, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body)
+	}
+
+	return cat([]byte("fLaC"), block(flacStreamInfo, false, info), block(1, false, make([]byte, 8)),
+		block(flacVorbisComment, true, vorbis))
+}
+
+// mp3Frame is MPEG1 layer III, 128 kbit/s, 44100 Hz, stereo
+func mp3Frame(xingFrames uint32) []byte {
+	f := make([]byte, 417)
+	copy(f, []byte{0xFF, 0xFB, 0x90, 0x00})
+	if xingFrames > 0 {
+		copy(f[36:], cat([]byte("Xing"), be(1), be(xingFrames)))
+	}
+	return f
+}
+
+func mp4() []byte {
+	box := func(kind string, body ...[]byte) []byte {
+		b := cat(body...)
+		return cat(be(uint32(len(b)+8)), []byte(kind), b)
+	}
+	item := func(kind string, value []byte) []byte {
+		return box(kind, box("data", be(1), be(0), value))
+	}
+
+	mvhd := make([]byte, 100)
+	copy(mvhd[12:], be(1000))
+	copy(mvhd[16:], be(215000))
+
+	ilst := box("ilst",
+		item("\xa9ART", []byte("artist")),
+		item("\xa9alb", []byte("album")),
+		item("\xa9nam", []byte("track")),
+		item("trkn", []byte{0, 0, 0, 3, 0, 12, 0, 0}),
+		box("----", box("mean", be(0), []byte("com.apple.iTunes")), box("name", be(0), []byte("MusicBrainz Track Id")),
+			box("data", be(1), be(0), []byte(mbid))),
+		item("aART", []byte("album artist")),
+		item("disk", []byte{0, 0, 0, 1, 0, 2}),
+		item("gnre", []byte{0, 18}),
+		item("\xa9day", []byte("2004")),
+		item("\xa9wrt", []byte("composer")),
+	)
+
+	return cat(
+		box("ftyp", []byte("M4A "), be(0)),
+		box("moov", box("mvhd", mvhd), box("udta", box("meta", be(0), box("hdlr", make([]byte, 25)), ilst))),
+		box("mdat", make([]byte, 64)),
+	)
+}
+
+func dsf() []byte {
+	tag := id3(3, commonFrames...)
+	data := cat([]byte("data"), binary.LittleEndian.AppendUint64(nil, 12+64), make([]byte, 64))
+	metadata := uint64(28 + 52 + len(data))
+
+	format := cat([]byte("fmt "), binary.LittleEndian.AppendUint64(nil, 52), le(1), le(0), le(2), le(2),
+		le(2822400), le(1), binary.LittleEndian.AppendUint64(nil, 2822400*215), le(4096), le(0))
+	header := cat([]byte("DSD "), binary.LittleEndian.AppendUint64(nil, 28),
+		binary.LittleEndian.AppendUint64(nil, metadata+uint64(len(tag))), binary.LittleEndian.AppendUint64(nil, metadata))
+
+	return cat(header, format, data, tag)
+}
+
+func wav() []byte {
+	chunk := func(id string, body []byte) []byte {
+		res := cat([]byte(id), le(uint32(len(body))), body)
+		if len(body)%2 == 1 {
+			res = append(res, 0)
+		}
+		return res
+	}
+
+	format := cat([]byte{1, 0, 2, 0}, le(44100), le(176400), []byte{4, 0, 16, 0})
+	info := cat([]byte("INFO"), chunk("IART", []byte("artist\x00")), chunk("INAM", []byte("track\x00")),
+		chunk("IPRD", []byte("album\x00")), chunk("ITRK", []byte("3\x00")), chunk("IGNR", []byte("Rock\x00")),
+		chunk("ICRD", []byte("2004\x00")))
+	body := cat([]byte("WAVE"), chunk("fmt ", format), chunk("LIST", info), chunk("data", make([]byte, 176400*3)),
+		chunk("id3 ", id3(3, append([]frame{ufid}, extendedFrames...)...)))
+
+	return cat([]byte("RIFF"), le(uint32(len(body))), body)
+}
+
+func TestRead(t *testing.T) {
+	tagged := Tags{Artist: "artist", Album: "album", Title: "track", TrackNumber: "3", MusicBrainzTID: mbid,
+		AlbumArtist: "album artist", DiscNumber: "1", Genre: "Rock", Date: "2004", Composer: "composer"}
+	with := func(d time.Duration, rate, channels, depth int) *Tags {
+		res := tagged
+		res.Duration, res.SampleRate, res.Channels, res.BitDepth = d, rate, channels, depth
+		return &res
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *Tags
+		wantErr bool
+	}{
+		{
+			name: "flac",
+			data: flac(),
+			want: with(215*time.Second, 44100, 2, 16),
+		},
+		{
+			name: "flac with id3",
+			data: cat(id3(3, frame{"TPE1", latin1("ignored")}), flac()),
+			want: &Tags{Artist: "ignored", Album: "album", Title: "track", TrackNumber: "3", MusicBrainzTID: mbid,
+				AlbumArtist: "album artist", DiscNumber: "1", Genre: "Rock", Date: "2004", Composer: "composer",
+				Duration: 215 * time.Second, SampleRate: 44100, Channels: 2, BitDepth: 16},
+		},
+		{
+			name: "mp3 id3v2.3 xing",
+			data: cat(id3(3, commonFrames...), mp3Frame(1000), mp3Frame(0)),
+			want: with(1000*1152*time.Second/44100, 44100, 2, 0),
+		},
+		{
+			name: "mp3 id3v2.4 cbr",
+			data: cat(id3(4, frame{"TPE1", utf16LE("artist")}, frame{"TALB", []byte("\x03album")},
+				frame{"TIT2", []byte("\x03track\x00other")}, frame{"TRCK", latin1("3")}, ufid,
+				frame{"TPE2", latin1("album artist")}, frame{"TPOS", latin1("1")}, frame{"TCON", latin1("Rock")},
+				frame{"TDRC", latin1("2004")}, frame{"TCOM", latin1("composer")}), mp3Frame(0), make([]byte, 160000-417)),
+			want: with(10*time.Second, 44100, 2, 0),
+		},
+		{
+			name: "mp3 tlen",
+			data: cat(id3(3, append(commonFrames, frame{"TLEN", latin1("215000")})...), mp3Frame(1000)),
+			want: with(215*time.Second, 0, 0, 0),
+		},
+		{
+			name: "mp3 without tags",
+			data: cat(mp3Frame(0), make([]byte, 160000-417)),
+			want: &Tags{Duration: 10 * time.Second, SampleRate: 44100, Channels: 2},
+		},
+		{
+			name: "m4a",
+			data: mp4(),
+			want: with(215*time.Second, 0, 0, 0),
+		},
+		{
+			name: "dsf",
+			data: dsf(),
+			want: with(215*time.Second, 2822400, 2, 1),
+		},
+		{
+			name: "wav",
+			data: wav(),
+			want: with(3*time.Second, 44100, 2, 16),
+		},
+		{
+			name:    "unsupported",
+			data:    []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "file")
+			if err := os.WriteFile(filename, tt.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := Read(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Read() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func Test_genre(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "Rock", want: "Rock"},
+		{s: "(17)", want: "Rock"},
+		{s: "17", want: "Rock"},
+		{s: "(17)Classic Rock", want: "Classic Rock"},
+		{s: "(255)", want: ""},
+		{s: "(Rock", want: "(Rock"},
+		{s: "", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			if got := genre(tt.s); got != tt.want {
+				t.Errorf("genre() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFrom_malformed(t *testing.T) {
+	box := func(kind string, body []byte) []byte { return cat(be(uint32(len(body)+8)), []byte(kind), body) }
+	ftyp := box("ftyp", cat([]byte("M4A "), be(0)))
+	huge := uint32(0xFFFFFFF0)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "mp4 without moov, short box at the end",
+			data: cat(ftyp, box("free", nil)),
+		},
+		{
+			name: "mp4 truncated 64-bit box size",
+			data: cat(ftyp, be(1), []byte("mdat"), []byte{0, 0}),
+		},
+		{
+			name: "mp4 box larger than file",
+			data: cat(ftyp, be(huge), []byte("moov"), make([]byte, 16)),
+		},
+		{
+			name: "flac huge vendor length",
+			data: cat([]byte("fLaC"), []byte{0x80 | flacVorbisComment, 0, 0, 12}, le(huge), make([]byte, 8)),
+		},
+		{
+			name: "flac huge comment length",
+			data: cat([]byte("fLaC"), []byte{0x80 | flacVorbisComment, 0, 0, 16}, le(0), le(1), le(huge), le(0)),
+		},
+		{
+			name: "wav huge info subchunk",
+			data: cat([]byte("RIFF"), le(28), []byte("WAVE"), []byte("LIST"), le(16), []byte("INFO"), []byte("IART"),
+				le(huge), le(0)),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// no panic, result doesn't matter
+			_, _ = ReadFrom(bytes.NewReader(tt.data), int64(len(tt.data)))
+		})
+	}
+}
+
+// FuzzReadFrom checks that corrupt files don't crash the daemon
+func FuzzReadFrom(f *testing.F) {
+	for _, data := range [][]byte{flac(), cat(id3(3, commonFrames...), mp3Frame(1000)), mp3Frame(0), mp4(), dsf(),
+		wav()[:4096]} {
+		f.Add(data)
+
+		// truncated files, e.g. partially copied to the device
+		for _, n := range []int{13, 40, 100, len(data) / 2} {
+			f.Add(data[:min(n, len(data))])
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = ReadFrom(bytes.NewReader(data), int64(len(data)))
+	})
+}
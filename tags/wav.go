@@ -0,0 +1,102 @@
+package tags
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// wavMaxList limits size of LIST chunk read into memory
+const wavMaxList = 1024 * 1024
+
+// readWAV reads fmt and data chunks for duration, LIST/INFO and id3 chunks for tags
+func readWAV(r io.ReaderAt, size int64, t *Tags) error {
+	var byteRate, dataSize int64
+	off := int64(12)
+
+	for off+8 <= size {
+		header, err := readAt(r, off, 8)
+		if err != nil {
+			return fmt.Errorf("cannot read wav chunk header: %w", err)
+		}
+
+		id := string(header[0:4])
+		chunkSize := int64(le32(header[4:8]))
+		body := off + 8
+
+		switch id {
+		case "fmt ":
+			b, err := readAt(r, body, 16)
+			if err != nil {
+				return fmt.Errorf("cannot read wav fmt chunk: %w", err)
+			}
+
+			t.Channels = int(binary.LittleEndian.Uint16(b[2:4]))
+			t.SampleRate = int(le32(b[4:8]))
+			byteRate = int64(le32(b[8:12]))
+			t.BitDepth = int(binary.LittleEndian.Uint16(b[14:16]))
+		case "data":
+			dataSize = min(chunkSize, size-body)
+		case "LIST":
+			if chunkSize > wavMaxList {
+				break
+			}
+
+			b, err := readAt(r, body, int(chunkSize))
+			if err != nil {
+				return fmt.Errorf("cannot read wav list chunk: %w", err)
+			}
+
+			if len(b) >= 4 && string(b[0:4]) == "INFO" {
+				wavInfo(b[4:], t)
+			}
+		case "id3 ", "ID3 ":
+			if _, err = readID3(r, body, t); err != nil {
+				return fmt.Errorf("cannot read wav id3 chunk: %w", err)
+			}
+		}
+
+		off = body + chunkSize + chunkSize%2
+	}
+
+	if byteRate > 0 && t.Duration == 0 {
+		t.Duration = time.Duration(dataSize) * time.Second / time.Duration(byteRate)
+	}
+
+	return nil
+}
+
+// wavInfo reads null-terminated values of INFO subchunks
+func wavInfo(b []byte, t *Tags) {
+	for len(b) >= 8 {
+		id := string(b[0:4])
+		size := int64(le32(b[4:8]))
+		if size > int64(len(b)-8) {
+			return
+		}
+
+		value := strings.TrimSpace(strings.TrimRight(string(b[8:8+size]), "\x00"))
+		switch id {
+		case "IART":
+			t.Artist = first(t.Artist, value)
+		case "IPRD":
+			t.Album = first(t.Album, value)
+		case "INAM":
+			t.Title = first(t.Title, value)
+		case "IGNR":
+			t.Genre = first(t.Genre, value)
+		case "ICRD":
+			t.Date = first(t.Date, value)
+		case "ITRK", "IPRT":
+			t.TrackNumber = first(t.TrackNumber, value)
+		}
+
+		size += size % 2
+		if size > int64(len(b)-8) {
+			return
+		}
+		b = b[8+size:]
+	}
+}